@@ -0,0 +1,30 @@
+package circuitbreaker_test
+
+import (
+	"testing"
+	"time"
+)
+
+// Execute should not allocate on the hot path, the buckets are allocated once and reused
+// and the state is evaluated once per request
+func BenchmarkExecute(b *testing.B) {
+	cb := newBreaker(b, 10, 10, 0.5, time.Second)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = cb.Execute(succeed)
+	}
+}
+
+func BenchmarkExecuteParallel(b *testing.B) {
+	cb := newBreaker(b, 10, 10, 0.5, time.Second)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = cb.Execute(succeed)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketRotationClearsExpiredBuckets(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name         string
+		elapsed      time.Duration
+		wantRequests int
+		wantFailures int
+	}{
+		{name: "same bucket", elapsed: 0, wantRequests: 4, wantFailures: 2},
+		{name: "first bucket expired", elapsed: 3 * time.Second, wantRequests: 2, wantFailures: 0},
+		{name: "whole window expired", elapsed: 10 * time.Second, wantRequests: 0, wantFailures: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a 4s window of 1s buckets and a threshold which never opens
+			cb, err := NewCircuitBreaker(4, 1, 2, time.Second, nil)
+			if err != nil {
+				t.Fatalf("NewCircuitBreaker() error = %v", err)
+			}
+
+			cb.record(errFailed)
+			cb.record(errFailed)
+			cb.lastBucketTime = cb.lastBucketTime.Add(-time.Second)
+			cb.record(nil)
+			cb.record(nil)
+
+			cb.lastBucketTime = cb.lastBucketTime.Add(-tt.elapsed)
+			cb.getBucketIndex()
+
+			if cb.totalRequests != tt.wantRequests || cb.totalFailures != tt.wantFailures {
+				t.Errorf("requests/failures = %d/%d, want %d/%d",
+					cb.totalRequests, cb.totalFailures, tt.wantRequests, tt.wantFailures)
+			}
+		})
+	}
+}
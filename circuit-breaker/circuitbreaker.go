@@ -7,14 +7,23 @@ State transfer should be statistically evaluated to avoid false positives and ne
 package circuitbreaker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	floatOne = 1.0
+	floatOne     = 1.0
+	floatEpsilon = 1e-9
+
+	defaultHalfOpenMaxRequests = 10
 )
 
 var (
@@ -30,9 +39,10 @@ const (
 	HalfOpen
 )
 
+// Bucket holds the requests and failures counted during one slice of the window
 type Bucket struct {
-	requests int
-	failures int
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
 }
 
 type RetryPolicy struct {
@@ -47,10 +57,14 @@ type halfOpenInfo struct {
 	CurrentPercentage              float64
 	OnFlightRequest                float64
 	MaxRequest                     float64
+	// StageRequests and StageFailures count the finished probes of the current stage
+	StageRequests float64
+	StageFailures float64
 }
 
+// NextStep returns the stage following CurrentPercentage, the last stage is followed by 1.0
 func (h *halfOpenInfo) NextStep() float64 {
-	for idx, percent := range DefaultHalfOpenPercentages {
+	for idx, percent := range h.HalfOpenStages {
 		if h.CurrentPercentage == percent {
 			if idx == (len(h.HalfOpenStages) - 1) {
 				return floatOne
@@ -75,6 +89,22 @@ func (h *halfOpenInfo) ZeroState() {
 	h.LastHalfOpenRequest = time.Time{}
 	h.OnFlightRequest = 0
 	h.CurrentPercentage = h.HalfOpenStages[0]
+	h.resetStage()
+}
+
+func (h *halfOpenInfo) resetStage() {
+	h.StageRequests = 0
+	h.StageFailures = 0
+}
+
+// allowedRequests is the number of concurrent probes admitted in the current stage, at least one
+func (h *halfOpenInfo) allowedRequests() float64 {
+	allowed := h.MaxRequest * h.CurrentPercentage
+	if allowed < floatOne {
+		return floatOne
+	}
+
+	return allowed
 }
 
 // HttpRequester is the interface abstracting http.Client
@@ -84,93 +114,265 @@ type HttpRequester interface {
 }
 
 type CircuitBreaker struct {
+	// droppedRequests is accessed atomically, as the first field it is 64-bit aligned on 32-bit platforms
+	droppedRequests      uint64
 	lastStateChange      time.Time
 	lastBucketTime       time.Time
 	requester            HttpRequester
+	clock                Clock
 	halfOpenInfo         *halfOpenInfo
 	buckets              []Bucket
 	threshold            float64
+	successThreshold     float64
 	currentRate          float64
 	stateStepInterval    time.Duration
 	changeBucketDuration time.Duration
+	callTimeout          time.Duration
 	windowInSeconds      int
 	bucketPerSecond      int
 	totalRequests        int
 	totalFailures        int
 	lastIndex            int
-	currentState         State
-	mu                   sync.RWMutex
+	// stateChanges counts the transitions, a fake clock may report the same time for several of them
+	stateChanges uint64
+	currentState State
+	mu           sync.RWMutex
+	isFailure    func(error) bool
+	lg           *slog.Logger
+	// debug receives a line per decision of allow and stateEval, it is nil unless WithDebugWriter is used
+	debug io.Writer
+	// debugMu serializes the writes to debug, Allow only holds the read lock
+	debugMu sync.Mutex
+	// panicAsError returns the panics of f as ErrPanic errors instead of panicking again
+	panicAsError bool
+	// slots limits the concurrent calls, it is nil when they are unlimited
+	slots chan struct{}
+	// closedCh is closed on the next transition to Closed, it is nil until WaitClosed needs it
+	closedCh chan struct{}
+}
+
+// Option configures optional CircuitBreaker settings
+type Option func(*CircuitBreaker)
+
+// WithHalfOpenMaxRequests sets the number of concurrent probes admitted in HalfOpen once the ramp reaches 100%,
+// each stage admits its percentage of it. The default is 10.
+func WithHalfOpenMaxRequests(n int) Option {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenInfo.MaxRequest = float64(n)
+	}
+}
+
+// WithHalfOpenSuccessThreshold sets the ratio of successful probes a HalfOpen stage needs to ramp up to the next one.
+// A stage is evaluated once it finished as many probes as it admits concurrently, it opens the CircuitBreaker as
+// soon as the ratio can not be reached anymore. The default is 1 - threshold, the failure rate tolerated in Closed.
+func WithHalfOpenSuccessThreshold(ratio float64) Option {
+	return func(cb *CircuitBreaker) {
+		cb.successThreshold = ratio
+	}
 }
 
+// WithFailurePredicate sets which errors returned from f count as failures, the other errors are still returned
+// to the caller but count as successful requests. By default every non-nil error is a failure.
+func WithFailurePredicate(isFailure func(error) bool) Option {
+	return func(cb *CircuitBreaker) {
+		cb.isFailure = isFailure
+	}
+}
+
+// WithCallTimeout limits the duration of each call made through ExecuteContext and DoRequest,
+// a call exceeding d returns context.DeadlineExceeded which counts as a failure.
+func WithCallTimeout(d time.Duration) Option {
+	return func(cb *CircuitBreaker) {
+		cb.callTimeout = d
+	}
+}
+
+// WithMaxConcurrent limits the calls running f at the same time to n, a request exceeding the limit is rejected
+// with ErrTooManyConcurrent without calling f and without counting as a failure. A non-positive n means no limit.
+func WithMaxConcurrent(n int) Option {
+	return func(cb *CircuitBreaker) {
+		if n > 0 {
+			cb.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithClock replaces the wall clock the CircuitBreaker measures time with, tests can use it to drive
+// the state transitions deterministically.
+func WithClock(c Clock) Option {
+	return func(cb *CircuitBreaker) {
+		cb.clock = c
+	}
+}
+
+// WithLogger logs every state transition to lg, at Warn when the CircuitBreaker opens and at Info otherwise,
+// with the failure rate and the request counts at the moment of the transition.
+func WithLogger(lg *slog.Logger) Option {
+	return func(cb *CircuitBreaker) {
+		cb.lg = lg
+	}
+}
+
+// WithDebugWriter writes a line per decision of Allow, Execute and StateEval to w, with the state, the failure rate,
+// the threshold and the current bucket, to help tuning the window and the threshold. Writes to w are serialized by
+// the CircuitBreaker and their errors are ignored.
+func WithDebugWriter(w io.Writer) Option {
+	return func(cb *CircuitBreaker) {
+		cb.debug = w
+	}
+}
+
+// WithPanicAsError returns a panic of f from Execute as an error wrapping ErrPanic. By default the panic is
+// counted as a failure and Execute panics again with the recovered value.
+func WithPanicAsError() Option {
+	return func(cb *CircuitBreaker) {
+		cb.panicAsError = true
+	}
+}
+
+// ZeroState resets the buckets, the counters and the half-open ramp
 func (cb *CircuitBreaker) ZeroState() {
 	cb.mu.Lock()
 	defer cb.mu.Unlock()
 
-	cb.lastBucketTime = time.Time{}
+	cb.zeroState()
+}
+
+func (cb *CircuitBreaker) zeroState() {
 	cb.halfOpenInfo.ZeroState()
 	for idx := range cb.buckets {
 		cb.buckets[idx] = Bucket{}
 	}
 
+	cb.resetWindow()
+}
+
+// resetWindow resets the counters of the sliding window, the buckets should be empty already
+func (cb *CircuitBreaker) resetWindow() {
+	cb.lastBucketTime = time.Time{}
 	cb.lastIndex = 0
-	cb.currentRate = 1.0
+	cb.currentRate = 0
 
 	cb.totalFailures = 0
 	cb.totalRequests = 0
 }
 
+// Resize replaces the sliding window with an empty one of windowInSeconds divided into bucketsPerSecond buckets.
+// The state of the CircuitBreaker and its half-open ramp are kept, only the counted requests are dropped.
+// A windowInSeconds or bucketsPerSecond which is not positive is rejected with an error and changes nothing.
+func (cb *CircuitBreaker) Resize(windowInSeconds, bucketsPerSecond int) error {
+	if err := validateWindow(windowInSeconds, bucketsPerSecond); err != nil {
+		return err
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.windowInSeconds = windowInSeconds
+	cb.bucketPerSecond = bucketsPerSecond
+	cb.changeBucketDuration = time.Second / time.Duration(bucketsPerSecond)
+	cb.buckets = make([]Bucket, windowInSeconds*bucketsPerSecond)
+	cb.resetWindow()
+
+	return nil
+}
+
 // NewCircuitBreaker creates a new CircuitBreaker with the given windowInSeconds, bucketPerSecond and breakigThreshold.
 // The windowInSeconds is the total time in seconds that the CircuitBreaker will keep track of.
 // The bucketPerSecond is the number of buckets that the windowInSeconds will be divided into.
 // The breakigThreshold is the percentage of failures that will cause the CircuitBreaker to open.
-// The StateHandler is the handler that will be used to evaluate the state of the CircuitBreaker.
+// The stateStepInterval is the time the CircuitBreaker stays Open before it starts probing in HalfOpen.
+// A windowInSeconds or bucketPerSecond which is not positive is rejected with the same error as Resize.
 func NewCircuitBreaker(windowInSeconds, bucketsPerSecond int,
-	threshold float64, stateStepInterval time.Duration, req HttpRequester) *CircuitBreaker {
-	return &CircuitBreaker{
-		windowInSeconds:   windowInSeconds,
-		bucketPerSecond:   bucketsPerSecond,
-		threshold:         threshold,
-		stateStepInterval: stateStepInterval,
-		buckets:           make([]Bucket, windowInSeconds*bucketsPerSecond),
-		requester:         req,
+	threshold float64, stateStepInterval time.Duration, req HttpRequester, opts ...Option) (*CircuitBreaker, error) {
+	if err := validateWindow(windowInSeconds, bucketsPerSecond); err != nil {
+		return nil, err
+	}
+
+	cb := &CircuitBreaker{
+		windowInSeconds:      windowInSeconds,
+		bucketPerSecond:      bucketsPerSecond,
+		threshold:            threshold,
+		successThreshold:     floatOne - threshold,
+		stateStepInterval:    stateStepInterval,
+		changeBucketDuration: time.Second / time.Duration(bucketsPerSecond),
+		buckets:              make([]Bucket, windowInSeconds*bucketsPerSecond),
+		requester:            req,
+		clock:                realClock{},
+		halfOpenInfo:         &halfOpenInfo{MaxRequest: defaultHalfOpenMaxRequests},
+	}
+
+	for _, o := range opts {
+		o(cb)
 	}
+
+	cb.zeroState()
+	return cb, nil
 }
 
+// validateWindow rejects a window which would have no bucket
+func validateWindow(windowInSeconds, bucketsPerSecond int) error {
+	if windowInSeconds <= 0 || bucketsPerSecond <= 0 {
+		return fmt.Errorf("%w: %d seconds of %d buckets", errInvalidWindow, windowInSeconds, bucketsPerSecond)
+	}
+
+	return nil
+}
+
+// getBucketIndex returns the bucket of the current time, every bucket which fell out of the window since
+// the last call is cleared and its counts are removed from the totals.
 func (cb *CircuitBreaker) getBucketIndex() int {
+	now := cb.clock.Now()
 	if cb.lastBucketTime.IsZero() {
-		cb.lastBucketTime = time.Now()
+		cb.lastBucketTime = now
 		cb.buckets[cb.lastIndex] = Bucket{}
 	}
 
-	if time.Since(cb.lastBucketTime) < cb.changeBucketDuration {
+	steps := int(now.Sub(cb.lastBucketTime) / cb.changeBucketDuration)
+	if steps == 0 {
 		return cb.lastIndex
 	}
 
-	outDatedBucket := cb.buckets[cb.lastIndex]
+	if steps >= len(cb.buckets) {
+		// the whole window is outdated
+		for idx := range cb.buckets {
+			cb.buckets[idx] = Bucket{}
+		}
+
+		cb.totalRequests = 0
+		cb.totalFailures = 0
+	}
 
-	// clean up the outdated values
-	cb.totalRequests -= outDatedBucket.requests
-	cb.totalFailures -= outDatedBucket.failures
+	for i := 0; i < steps && i < len(cb.buckets); i++ {
+		// the next bucket holds the oldest values of the window
+		cb.lastIndex = (cb.lastIndex + 1) % len(cb.buckets)
+		outDatedBucket := cb.buckets[cb.lastIndex]
 
-	// reset the bucket and recalculating the last index and current rate
-	cb.lastIndex = (cb.lastIndex + 1) % len(cb.buckets)
-	cb.buckets[cb.lastIndex] = Bucket{}
+		// clean up the outdated values
+		cb.totalRequests -= outDatedBucket.Requests
+		cb.totalFailures -= outDatedBucket.Failures
+		cb.buckets[cb.lastIndex] = Bucket{}
+	}
 
-	cb.lastBucketTime = time.Now()
+	// moving by whole buckets keeps the bucket boundaries from drifting
+	cb.lastBucketTime = cb.lastBucketTime.Add(time.Duration(steps) * cb.changeBucketDuration)
 
 	cb.updateStats()
 	return cb.lastIndex
 }
 
-// MakeRequest registers a request and a failure in the current bucket.
+// Execute runs f when the CircuitBreaker admits the request and registers its outcome in the current bucket.
 // It then updates the stats and evaluates the state of the CircuitBreaker.
-// If the CircuitBreaker is in the Open state, it will return an error.
+// If the request is not admitted, f is not called and ErrRequestDropped is returned.
+// f runs without holding the lock, in HalfOpen only a limited number of concurrent probes are admitted.
+// With WithMaxConcurrent, a request finding no free slot returns ErrTooManyConcurrent.
+// A panic of f always counts as a failure, it is raised again once the outcome is registered, see WithPanicAsError.
 //
 // Client is responisble for handling the error and determining which errors should be counted as
 // error for circuit breaker
 // e.x:
 //
-//	err := cb.MakeRequest(&cb.RetryPolicy{Count: 3, Wailt: time.Second*3},func() error {
+//	err := cb.Execute(func() error {
 //		res, err := http.Get("http://example.com")
 //		if err != nil {
 //			return err
@@ -193,53 +395,258 @@ func (cb *CircuitBreaker) getBucketIndex() int {
 //		return nil
 //	})
 func (cb *CircuitBreaker) Execute(f func() error) error {
+	if !cb.acquireSlot() {
+		return ErrTooManyConcurrent
+	}
+
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	cb.halfOpenIfDue()
+	if !cb.allow() {
+		cb.mu.Unlock()
+		cb.releaseSlot()
+		atomic.AddUint64(&cb.droppedRequests, 1)
+		return ErrRequestDropped
+	}
+
+	// a probe only counts for the HalfOpen period it was admitted in
+	probe := cb.currentState == HalfOpen
+	admittedAt := cb.stateChanges
+	if probe {
+		cb.halfOpenInfo.OnFlightRequest++
+		cb.halfOpenInfo.LastHalfOpenRequest = cb.clock.Now()
+	}
+	cb.mu.Unlock()
 
-	defer cb.StateEval()
+	err := run(f)
+	cb.releaseSlot()
+	failure := cb.failure(err)
 
-	if !cb.Allow() {
-		return ErrRequestDropped
+	cb.mu.Lock()
+	cb.record(failure)
+	if probe && cb.stateChanges == admittedAt {
+		cb.halfOpenInfo.OnFlightRequest--
+		cb.checkHalfOpenState(failure)
+	}
+
+	cb.stateEval()
+	cb.mu.Unlock()
+
+	if pe := asPanic(err); pe != nil && !cb.panicAsError {
+		panic(pe.value)
 	}
 
+	return err
+}
+
+// panicError is the error a panic of f is returned as, it wraps ErrPanic
+type panicError struct {
+	value any
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrPanic, e.value)
+}
+
+func (e *panicError) Unwrap() error {
+	return ErrPanic
+}
+
+// asPanic returns the panicError in the chain of err or nil, the target of errors.As escapes so it is only
+// allocated for an error to keep the successful calls free of allocations
+func asPanic(err error) *panicError {
+	if err == nil {
+		return nil
+	}
+
+	var pe *panicError
+	if errors.As(err, &pe) {
+		return pe
+	}
+
+	return nil
+}
+
+// run calls f and returns its panic as a panicError
+func run(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+
+	return f()
+}
+
+// acquireSlot takes a concurrency slot without blocking, it always succeeds without WithMaxConcurrent
+func (cb *CircuitBreaker) acquireSlot() bool {
+	if cb.slots == nil {
+		return true
+	}
+
+	select {
+	case cb.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (cb *CircuitBreaker) releaseSlot() {
+	if cb.slots != nil {
+		<-cb.slots
+	}
+}
+
+// ExecuteContext works like Execute but passes ctx to f, when a call timeout is set f runs in its own goroutine
+// and ExecuteContext returns as soon as the timeout expires.
+// f should return once ctx is done, otherwise its goroutine keeps running until f returns on its own.
+// A panic of f in its own goroutine is handled like in Execute, unless it happens after the timeout.
+// The ctx passed to f is marked with WithBreaker, a nested ExecuteContext of the same CircuitBreaker with it runs
+// f directly, so a layered client calling the same dependency is admitted and counted once.
+func (cb *CircuitBreaker) ExecuteContext(ctx context.Context, f func(ctx context.Context) error) error {
+	if insideBreaker(ctx, cb) {
+		return f(ctx)
+	}
+
+	ctx = WithBreaker(ctx, cb)
+	return cb.Execute(func() error {
+		return cb.call(ctx, f)
+	})
+}
+
+// breakerKey is the context key marking a context as already running inside cb
+type breakerKey struct {
+	cb *CircuitBreaker
+}
+
+// WithBreaker returns a copy of ctx marked as running inside cb, ExecuteContext of cb runs f directly with it
+// instead of admitting and counting the call again. ExecuteContext marks the ctx of f itself, use WithBreaker
+// when the outer call is admitted by other means, like Allow.
+func WithBreaker(ctx context.Context, cb *CircuitBreaker) context.Context {
+	return context.WithValue(ctx, breakerKey{cb: cb}, true)
+}
+
+func insideBreaker(ctx context.Context, cb *CircuitBreaker) bool {
+	inside, _ := ctx.Value(breakerKey{cb: cb}).(bool)
+	return inside
+}
+
+func (cb *CircuitBreaker) call(ctx context.Context, f func(ctx context.Context) error) error {
+	if cb.callTimeout <= 0 {
+		return f(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cb.callTimeout)
+	defer cancel()
+
+	// buffered, so f can still finish after the timeout without blocking its goroutine forever
+	done := make(chan error, 1)
+	go func() {
+		done <- run(func() error { return f(ctx) })
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// failure returns err when it counts as a failure for the CircuitBreaker and nil otherwise, panics always count
+func (cb *CircuitBreaker) failure(err error) error {
+	if err == nil || asPanic(err) != nil || cb.isFailure == nil || cb.isFailure(err) {
+		return err
+	}
+
+	return nil
+}
+
+// record registers the request and its failure in the current bucket
+func (cb *CircuitBreaker) record(err error) {
 	idx := cb.getBucketIndex()
 
 	cb.totalRequests++
-	cb.buckets[idx].requests++
+	cb.buckets[idx].Requests++
 
-	err := f()
 	if err != nil {
 		cb.totalFailures++
-		cb.buckets[idx].failures++
+		cb.buckets[idx].Failures++
 	}
 
 	cb.updateStats()
-	cb.StateEval()
-
-	return err
 }
 
-func (cb *CircuitBreaker) DoRequest(req *http.Request) (resp *http.Response, err error) {
-	err = cb.Execute(func() error {
-		resp, err = cb.requester.Do(req)
+// DoRequest sends req through the requester, with a call timeout the request context is bounded by it.
+func (cb *CircuitBreaker) DoRequest(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	err := cb.ExecuteContext(req.Context(), func(ctx context.Context) error {
+		var err error
+		resp, err = cb.requester.Do(req.WithContext(ctx))
 		return err
 	})
-	return
+
+	return resp, err
 }
 
 func (cb *CircuitBreaker) updateStats() {
+	if cb.totalRequests == 0 {
+		cb.currentRate = 0
+		return
+	}
+
 	cb.currentRate = float64(cb.totalFailures) / float64(cb.totalRequests)
 }
 
+// Allow reports whether a request would be admitted without changing the state, an Open CircuitBreaker whose
+// stateStepInterval passed admits the probes of a fresh HalfOpen. The transition itself is left to Execute and
+// StateEval.
 func (cb *CircuitBreaker) Allow() bool {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 
+	return cb.allow()
+}
+
+// AllowN reports how many of n requests would be admitted at once, all of them in Closed, none in Open and
+// the probes left in the current stage of the ramp in HalfOpen. Like Allow it does not change the state, an Open
+// CircuitBreaker whose stateStepInterval passed reports the probes of the first stage.
+func (cb *CircuitBreaker) AllowN(n int) int {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	if n <= 0 || !cb.allow() {
+		return 0
+	}
+
+	if cb.currentState == Closed {
+		return n
+	}
+
+	free := int(math.Ceil(cb.halfOpenInfo.allowedRequests() - cb.halfOpenInfo.OnFlightRequest))
+	if free < n {
+		return free
+	}
+
+	return n
+}
+
+func (cb *CircuitBreaker) allow() bool {
+	allowed := cb.admit()
+	cb.debugf("allow state=%s allowed=%t rate=%.3f threshold=%.3f bucket=%d\n",
+		cb.currentState, allowed, cb.currentRate, cb.threshold, cb.lastIndex)
+
+	return allowed
+}
+
+// admit decides on a request without changing any state, it only needs the read lock. The ramp of an Open
+// CircuitBreaker is already reset by the transition to Open, so it answers like the HalfOpen it moves to.
+func (cb *CircuitBreaker) admit() bool {
 	switch cb.currentState {
 	case Closed:
-		return cb.closedAllow()
+		return true
 	case Open:
-		return false
+		return cb.halfOpenDue() && cb.halfOpenAllow()
 	case HalfOpen:
 		return cb.halfOpenAllow()
 	default:
@@ -247,60 +654,208 @@ func (cb *CircuitBreaker) Allow() bool {
 	}
 }
 
-func (cb *CircuitBreaker) closedAllow() bool {
-	return cb.currentRate < cb.threshold
+// halfOpenDue reports whether an Open CircuitBreaker stayed Open for stateStepInterval
+func (cb *CircuitBreaker) halfOpenDue() bool {
+	return cb.currentState == Open && cb.clock.Now().Sub(cb.lastStateChange) > cb.stateStepInterval
 }
 
-// TODO: check for todo section
-// checko for HalfOpen allow
-func (cb *CircuitBreaker) halfOpenAllow() bool {
-	if time.Since(cb.halfOpenInfo.LastHalfOpenRequest) > cb.halfOpenInfo.HalfOpenSubStateChangeInterval {
-		cb.checkHalfOpenState()
+// halfOpenIfDue moves an Open CircuitBreaker to HalfOpen once stateStepInterval passed, it needs the write lock
+func (cb *CircuitBreaker) halfOpenIfDue() {
+	if cb.halfOpenDue() {
+		cb.setState(HalfOpen)
 	}
+}
 
-	allowedReqNumbers := cb.halfOpenInfo.MaxRequest * cb.halfOpenInfo.CurrentPercentage
-
-	if allowedReqNumbers < 1.0 {
-		allowedReqNumbers = 1.0
+// debugf writes a line to the WithDebugWriter writer, if any
+func (cb *CircuitBreaker) debugf(format string, args ...any) {
+	if cb.debug == nil {
+		return
 	}
 
-	return math.Abs(allowedReqNumbers-cb.halfOpenInfo.OnFlightRequest) < 0.01
+	cb.debugMu.Lock()
+	defer cb.debugMu.Unlock()
 
+	fmt.Fprintf(cb.debug, format, args...)
 }
 
-func (cb *CircuitBreaker) checkHalfOpenState() {
-	if (time.Since(cb.halfOpenInfo.LastHalfOpenRequest) >= cb.halfOpenInfo.HalfOpenSubStateChangeInterval) &&
-		float64(cb.totalFailures)/float64(cb.totalRequests) > 0.9 {
-		cb.halfOpenInfo.CurrentPercentage = cb.halfOpenInfo.NextStep()
+// halfOpenAllow admits probes until the allowed number of the current stage is in flight
+func (cb *CircuitBreaker) halfOpenAllow() bool {
+	return cb.halfOpenInfo.OnFlightRequest < cb.halfOpenInfo.allowedRequests()
+}
+
+// checkHalfOpenState registers a finished probe in the current stage. Once the stage finished a window of as many
+// probes as it admits, the traffic ramps up to the next stage when enough of them succeeded, the last stage closes
+// the CircuitBreaker. Too many failed probes open it again.
+func (cb *CircuitBreaker) checkHalfOpenState(err error) {
+	h := cb.halfOpenInfo
+
+	h.StageRequests++
+	if err != nil {
+		h.StageFailures++
+	}
+
+	window := math.Ceil(h.allowedRequests())
+	if window-h.StageFailures < window*cb.successThreshold-floatEpsilon {
+		cb.setState(Open)
+		return
 	}
 
-	if cb.halfOpenInfo.CurrentPercentage == 0 {
+	if h.StageRequests < window {
 		return
 	}
 
-	if cb.halfOpenInfo.CurrentPercentage > 0.9 {
+	if h.CurrentPercentage >= floatOne {
 		cb.setState(Closed)
+		return
 	}
+
+	h.CurrentPercentage = h.NextStep()
+	h.resetStage()
 }
 
 func (cb *CircuitBreaker) setState(state State) {
-	cb.ZeroState()
-	cb.lastStateChange = time.Now()
+	cb.logTransition(state)
+	cb.zeroState()
+	cb.lastStateChange = cb.clock.Now()
+	cb.stateChanges++
 	cb.currentState = state
+
+	if state == Closed && cb.closedCh != nil {
+		close(cb.closedCh)
+		cb.closedCh = nil
+	}
+}
+
+// logTransition logs the transition to state with the counters of the window at the moment of the transition
+func (cb *CircuitBreaker) logTransition(state State) {
+	if cb.lg == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	if state == Open {
+		level = slog.LevelWarn
+	}
+
+	cb.lg.LogAttrs(context.Background(), level, "circuit breaker state changed",
+		slog.String("from", cb.currentState.String()),
+		slog.String("to", state.String()),
+		slog.Float64("failureRate", cb.currentRate),
+		slog.Int("requests", cb.totalRequests),
+		slog.Int("failures", cb.totalFailures),
+	)
 }
 
-// Bring everuything here
+// StateEval opens a Closed CircuitBreaker once the failure rate reaches the threshold and moves an Open one
+// to HalfOpen after stateStepInterval, the HalfOpen transitions are driven by the probes in Execute.
 func (cb *CircuitBreaker) StateEval() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.stateEval()
+}
+
+func (cb *CircuitBreaker) stateEval() {
+	if cb.debug != nil {
+		from, rate := cb.currentState, cb.currentRate
+		defer func() {
+			cb.debugf("eval from=%s to=%s rate=%.3f threshold=%.3f bucket=%d\n",
+				from, cb.currentState, rate, cb.threshold, cb.lastIndex)
+		}()
+	}
+
 	switch cb.currentState {
-	case HalfOpen:
-		cb.checkHalfOpenState()
 	case Open:
-		if time.Since(cb.lastStateChange) > cb.stateStepInterval {
-			cb.setState(HalfOpen)
-		}
+		cb.halfOpenIfDue()
 	case Closed:
-		if cb.currentRate < cb.threshold {
-			cb.setState(Closed)
+		if cb.currentRate >= cb.threshold {
+			cb.setState(Open)
 		}
 	}
 }
+
+// Snapshot is a point in time view of a CircuitBreaker, it can be exposed as JSON on an admin API.
+type Snapshot struct {
+	State          State   `json:"state"`
+	FailureRate    float64 `json:"failureRate"`
+	TotalRequests  int     `json:"totalRequests"`
+	FailedRequests int     `json:"failedRequests"`
+	DroppedCount   uint64  `json:"droppedCount"`
+}
+
+// Snapshot returns the state and the counters of the current window of the CircuitBreaker.
+func (cb *CircuitBreaker) Snapshot() Snapshot {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return Snapshot{
+		State:          cb.currentState,
+		FailureRate:    cb.currentRate,
+		TotalRequests:  cb.totalRequests,
+		FailedRequests: cb.totalFailures,
+		DroppedCount:   cb.DroppedCount(),
+	}
+}
+
+// Buckets returns a copy of the buckets of the current window from the oldest to the newest. The buckets which
+// fell out of the window since the last request are reported empty.
+func (cb *CircuitBreaker) Buckets() []Bucket {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	n := len(cb.buckets)
+	buckets := make([]Bucket, n)
+	if cb.lastBucketTime.IsZero() {
+		return buckets
+	}
+
+	steps := int(cb.clock.Now().Sub(cb.lastBucketTime) / cb.changeBucketDuration)
+	if steps >= n {
+		return buckets
+	}
+
+	// the bucket following lastIndex is the oldest one, the steps oldest buckets are outdated
+	for i := steps; i < n; i++ {
+		buckets[i-steps] = cb.buckets[(cb.lastIndex+1+i)%n]
+	}
+
+	return buckets
+}
+
+// DroppedCount returns the number of requests rejected with ErrRequestDropped since the CircuitBreaker was created,
+// the requests rejected by WithMaxConcurrent are not included.
+func (cb *CircuitBreaker) DroppedCount() uint64 {
+	return atomic.LoadUint64(&cb.droppedRequests)
+}
+
+// WaitClosed blocks until the CircuitBreaker is Closed and returns nil, or returns the error of ctx once it is done.
+// The CircuitBreaker only recovers through the probes admitted in HalfOpen, so it takes other callers of Execute
+// to close it, a caller waiting alone waits until ctx is done.
+func (cb *CircuitBreaker) WaitClosed(ctx context.Context) error {
+	cb.mu.Lock()
+	if cb.currentState == Closed {
+		cb.mu.Unlock()
+		return nil
+	}
+
+	if cb.closedCh == nil {
+		cb.closedCh = make(chan struct{})
+	}
+	closed := cb.closedCh
+	cb.mu.Unlock()
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// State returns the current state of the CircuitBreaker
+func (cb *CircuitBreaker) State() State {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+
+	return cb.currentState
+}
@@ -0,0 +1,620 @@
+package circuitbreaker_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	circuitbreaker "github.com/OZahed/bob/circuit-breaker"
+)
+
+var errDependency = errors.New("dependency failed")
+
+func succeed() error { return nil }
+
+func fail() error { return errDependency }
+
+const stepInterval = 10 * time.Second
+
+// newBreaker creates a CircuitBreaker without a requester and fails the test when the window is invalid
+func newBreaker(tb testing.TB, window, buckets int, threshold float64, step time.Duration,
+	opts ...circuitbreaker.Option) *circuitbreaker.CircuitBreaker {
+	tb.Helper()
+
+	cb, err := circuitbreaker.NewCircuitBreaker(window, buckets, threshold, step, nil, opts...)
+	if err != nil {
+		tb.Fatalf("NewCircuitBreaker() error = %v", err)
+	}
+
+	return cb
+}
+
+// fakeClock is a Clock which only moves when it is advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestOpenMovesToHalfOpenAfterStepInterval(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval, circuitbreaker.WithClock(clock))
+
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Fatalf("State() = %v, want Open", got)
+	}
+
+	clock.Advance(stepInterval)
+	if cb.Allow() {
+		t.Fatal("Allow() = true at exactly stateStepInterval, want false")
+	}
+
+	clock.Advance(time.Nanosecond)
+	if !cb.Allow() {
+		t.Fatal("Allow() = false after stateStepInterval, want true")
+	}
+
+	// Allow only reports the admission, the transition is made by Execute and StateEval
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() after Allow() = %v, want Open", got)
+	}
+
+	cb.StateEval()
+	if got := cb.State(); got != circuitbreaker.HalfOpen {
+		t.Errorf("State() after StateEval() = %v, want HalfOpen", got)
+	}
+}
+
+func TestHalfOpenRampsBackToClosed(t *testing.T) {
+	clock := newFakeClock()
+	// a single probe per stage makes every stage a window of one probe
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(1),
+		circuitbreaker.WithClock(clock),
+	)
+
+	if err := cb.Execute(fail); !errors.Is(err, errDependency) {
+		t.Fatalf("Execute() error = %v, want %v", err, errDependency)
+	}
+
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Fatalf("State() = %v, want Open", got)
+	}
+
+	if err := cb.Execute(succeed); !errors.Is(err, circuitbreaker.ErrRequestDropped) {
+		t.Fatalf("Execute() error = %v, want %v", err, circuitbreaker.ErrRequestDropped)
+	}
+
+	clock.Advance(stepInterval + time.Nanosecond)
+
+	// every successful probe ramps one stage up, the last stage closes the breaker
+	for i := range circuitbreaker.DefaultHalfOpenPercentages {
+		if err := cb.Execute(succeed); err != nil {
+			t.Fatalf("probe %d: Execute() error = %v", i, err)
+		}
+
+		want := circuitbreaker.HalfOpen
+		if i == len(circuitbreaker.DefaultHalfOpenPercentages)-1 {
+			want = circuitbreaker.Closed
+		}
+
+		if got := cb.State(); got != want {
+			t.Fatalf("probe %d: State() = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestHalfOpenLimitsConcurrentProbes(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(5),
+		circuitbreaker.WithClock(clock),
+	)
+
+	_ = cb.Execute(fail)
+	clock.Advance(stepInterval + time.Nanosecond)
+
+	// the first stage admits 10% of 5 probes, rounded up to one
+	release := make(chan struct{})
+	admitted := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Execute(func() error {
+			close(admitted)
+			<-release
+			return nil
+		})
+	}()
+	<-admitted
+
+	if err := cb.Execute(succeed); !errors.Is(err, circuitbreaker.ErrRequestDropped) {
+		t.Errorf("Execute() error = %v, want %v", err, circuitbreaker.ErrRequestDropped)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe Execute() error = %v", err)
+	}
+
+	if err := cb.Execute(succeed); err != nil {
+		t.Errorf("Execute() after the probe finished error = %v", err)
+	}
+}
+
+func TestHalfOpenSuccessThreshold(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(10),
+		circuitbreaker.WithHalfOpenSuccessThreshold(0.5),
+		circuitbreaker.WithClock(clock),
+	)
+
+	_ = cb.Execute(fail)
+	clock.Advance(stepInterval + time.Nanosecond)
+
+	// 10% stage: a window of one probe, 30% stage: 2 of 3 probes succeed which is enough to ramp up
+	steps := []func() error{succeed, fail, succeed, succeed}
+	for i, step := range steps {
+		_ = cb.Execute(step)
+		if got := cb.State(); got != circuitbreaker.HalfOpen {
+			t.Fatalf("step %d: State() = %v, want HalfOpen", i, got)
+		}
+	}
+
+	// 50% stage: the third failure out of a window of 5 can not reach the threshold anymore
+	_ = cb.Execute(fail)
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.HalfOpen {
+		t.Fatalf("State() = %v, want HalfOpen", got)
+	}
+
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() = %v, want Open", got)
+	}
+}
+
+func TestFailedProbeReopens(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval, circuitbreaker.WithClock(clock))
+
+	_ = cb.Execute(fail)
+	clock.Advance(stepInterval + time.Nanosecond)
+
+	if err := cb.Execute(fail); !errors.Is(err, errDependency) {
+		t.Fatalf("Execute() error = %v, want %v", err, errDependency)
+	}
+
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() = %v, want Open", got)
+	}
+}
+
+func TestFailurePredicate(t *testing.T) {
+	errNotFound := errors.New("not found")
+	cb := newBreaker(t, 10, 1, 0.5, time.Second,
+		circuitbreaker.WithFailurePredicate(func(err error) bool {
+			return !errors.Is(err, errNotFound)
+		}),
+	)
+
+	if err := cb.Execute(func() error { return errNotFound }); !errors.Is(err, errNotFound) {
+		t.Fatalf("Execute() error = %v, want %v", err, errNotFound)
+	}
+
+	if got := cb.State(); got != circuitbreaker.Closed {
+		t.Fatalf("State() = %v, want Closed", got)
+	}
+
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() = %v, want Open", got)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	cb := newBreaker(t, 10, 1, 0.5, time.Second,
+		circuitbreaker.WithCallTimeout(10*time.Millisecond),
+	)
+
+	// f ignores its context, the breaker must not wait for it
+	hang := make(chan struct{})
+	defer close(hang)
+
+	err := cb.ExecuteContext(context.Background(), func(_ context.Context) error {
+		<-hang
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ExecuteContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() = %v, want Open", got)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	cb := newBreaker(t, 10, 1, 0.5, time.Second, circuitbreaker.WithMaxConcurrent(1))
+
+	release := make(chan struct{})
+	admitted := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- cb.Execute(func() error {
+			close(admitted)
+			<-release
+			return nil
+		})
+	}()
+	<-admitted
+
+	for i := 0; i < 3; i++ {
+		if err := cb.Execute(succeed); !errors.Is(err, circuitbreaker.ErrTooManyConcurrent) {
+			t.Fatalf("Execute() error = %v, want %v", err, circuitbreaker.ErrTooManyConcurrent)
+		}
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// the rejected requests are not failures
+	if got := cb.State(); got != circuitbreaker.Closed {
+		t.Errorf("State() = %v, want Closed", got)
+	}
+
+	if err := cb.Execute(succeed); err != nil {
+		t.Errorf("Execute() after the slot was released error = %v", err)
+	}
+}
+
+func TestResize(t *testing.T) {
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval, circuitbreaker.WithClock(newFakeClock()))
+
+	if err := cb.Resize(0, 1); err == nil {
+		t.Fatal("Resize() of an empty window error = nil")
+	}
+
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.Closed {
+		t.Fatalf("State() = %v, want Closed", got)
+	}
+
+	if err := cb.Resize(2, 10); err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+
+	// the successes were dropped with the old window, a single failure opens the breaker
+	_ = cb.Execute(fail)
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Fatalf("State() = %v, want Open", got)
+	}
+
+	// the state survives a resize
+	if err := cb.Resize(4, 1); err != nil {
+		t.Fatalf("Resize() error = %v", err)
+	}
+
+	if got := cb.State(); got != circuitbreaker.Open {
+		t.Errorf("State() = %v, want Open", got)
+	}
+}
+
+func TestDroppedCount(t *testing.T) {
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval, circuitbreaker.WithClock(newFakeClock()))
+
+	_ = cb.Execute(fail)
+	for i := 0; i < 3; i++ {
+		_ = cb.Execute(succeed)
+	}
+
+	if got := cb.DroppedCount(); got != 3 {
+		t.Errorf("DroppedCount() = %d, want 3", got)
+	}
+}
+
+func TestLoggerTransitions(t *testing.T) {
+	clock := newFakeClock()
+	buf := &bytes.Buffer{}
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(1),
+		circuitbreaker.WithClock(clock),
+		circuitbreaker.WithLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+	)
+
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(fail)
+	clock.Advance(stepInterval + time.Nanosecond)
+	_ = cb.Execute(fail)
+
+	type transition struct {
+		Level       string  `json:"level"`
+		From        string  `json:"from"`
+		To          string  `json:"to"`
+		FailureRate float64 `json:"failureRate"`
+		Requests    int     `json:"requests"`
+		Failures    int     `json:"failures"`
+	}
+
+	want := []transition{
+		{Level: "WARN", From: "closed", To: "open", FailureRate: 0.5, Requests: 2, Failures: 1},
+		{Level: "INFO", From: "open", To: "half-open"},
+		{Level: "WARN", From: "half-open", To: "open", FailureRate: 1, Requests: 1, Failures: 1},
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("logged %d transitions, want %d: %q", len(lines), len(want), lines)
+	}
+
+	for i, line := range lines {
+		var got transition
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("unmarshal log record: %v", err)
+		}
+
+		if got != want[i] {
+			t.Errorf("transition %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestPanicCountsAsFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []circuitbreaker.Option
+	}{
+		{name: "repanic"},
+		{name: "as error", opts: []circuitbreaker.Option{circuitbreaker.WithPanicAsError()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]circuitbreaker.Option{circuitbreaker.WithMaxConcurrent(1)}, tt.opts...)
+			cb := newBreaker(t, 10, 1, 0.6, time.Second, opts...)
+			_ = cb.Execute(succeed)
+
+			var err error
+			recovered := func() (r any) {
+				defer func() { r = recover() }()
+				err = cb.Execute(func() error { panic("boom") })
+				return nil
+			}()
+
+			if tt.opts == nil && recovered != "boom" {
+				t.Errorf("recovered = %v, want the panic value", recovered)
+			}
+
+			if tt.opts != nil && !errors.Is(err, circuitbreaker.ErrPanic) {
+				t.Errorf("Execute() error = %v, want %v", err, circuitbreaker.ErrPanic)
+			}
+
+			if got := cb.Snapshot(); got.FailedRequests != 1 || got.TotalRequests != 2 {
+				t.Errorf("Snapshot() = %+v, want the panic counted as a failure", got)
+			}
+
+			// neither the lock nor the concurrency slot may leak
+			if err := cb.Execute(succeed); err != nil {
+				t.Errorf("Execute() after the panic error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDebugWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithClock(newFakeClock()),
+		circuitbreaker.WithDebugWriter(buf),
+	)
+
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(fail)
+	_ = cb.Execute(succeed)
+
+	want := []string{
+		"allow state=closed allowed=true rate=0.000 threshold=0.500 bucket=0",
+		"eval from=closed to=closed rate=0.000 threshold=0.500 bucket=0",
+		"allow state=closed allowed=true rate=0.000 threshold=0.500 bucket=0",
+		"eval from=closed to=open rate=0.500 threshold=0.500 bucket=0",
+		"allow state=open allowed=false rate=0.000 threshold=0.500 bucket=0",
+	}
+
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("debug lines = %q, want %q", got, want)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 3, 1, 0.9, stepInterval, circuitbreaker.WithClock(clock))
+
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(succeed)
+	clock.Advance(time.Second)
+	_ = cb.Execute(fail)
+
+	want := []circuitbreaker.Bucket{{}, {Requests: 2}, {Requests: 1, Failures: 1}}
+	if got := cb.Buckets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets() = %v, want %v", got, want)
+	}
+
+	clock.Advance(2 * time.Second)
+	want = []circuitbreaker.Bucket{{Requests: 1, Failures: 1}, {}, {}}
+	if got := cb.Buckets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Buckets() after 2s = %v, want %v", got, want)
+	}
+}
+
+func TestWaitClosed(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(1),
+		circuitbreaker.WithClock(clock),
+	)
+
+	if err := cb.WaitClosed(context.Background()); err != nil {
+		t.Fatalf("WaitClosed() on a Closed breaker error = %v", err)
+	}
+
+	_ = cb.Execute(fail)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := cb.WaitClosed(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitClosed() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- cb.WaitClosed(context.Background())
+	}()
+
+	clock.Advance(stepInterval + time.Nanosecond)
+	for range circuitbreaker.DefaultHalfOpenPercentages {
+		_ = cb.Execute(succeed)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitClosed() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitClosed() did not return once the breaker closed")
+	}
+}
+
+func TestAllowN(t *testing.T) {
+	clock := newFakeClock()
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval,
+		circuitbreaker.WithHalfOpenMaxRequests(20),
+		circuitbreaker.WithClock(clock),
+	)
+
+	if got := cb.AllowN(5); got != 5 {
+		t.Errorf("AllowN(5) in Closed = %d, want 5", got)
+	}
+
+	_ = cb.Execute(fail)
+	if got := cb.AllowN(5); got != 0 {
+		t.Errorf("AllowN(5) in Open = %d, want 0", got)
+	}
+
+	clock.Advance(stepInterval + time.Nanosecond)
+	// the first stage of the ramp admits 10% of 20 probes
+	if got := cb.AllowN(5); got != 2 {
+		t.Errorf("AllowN(5) in HalfOpen = %d, want 2", got)
+	}
+
+	var inFlight int
+	_ = cb.Execute(func() error {
+		inFlight = cb.AllowN(5)
+		return nil
+	})
+	if inFlight != 1 {
+		t.Errorf("AllowN(5) with a probe in flight = %d, want 1", inFlight)
+	}
+
+	if got := cb.AllowN(0); got != 0 {
+		t.Errorf("AllowN(0) = %d, want 0", got)
+	}
+}
+
+// TestAllowConcurrentWithExecute moves the breaker through every state while Allow and AllowN are called,
+// run it with -race to check the admission checks do not race with the transitions.
+func TestAllowConcurrentWithExecute(t *testing.T) {
+	cb := newBreaker(t, 1, 10, 0.5, time.Millisecond,
+		circuitbreaker.WithHalfOpenMaxRequests(4),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if (i+j)%3 == 0 {
+					_ = cb.Execute(fail)
+				} else {
+					_ = cb.Execute(succeed)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				_ = cb.Allow()
+				_ = cb.AllowN(3)
+				cb.StateEval()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestNestedExecuteContext(t *testing.T) {
+	outer := newBreaker(t, 10, 1, 0.9, stepInterval)
+	other := newBreaker(t, 10, 1, 0.9, stepInterval)
+
+	err := outer.ExecuteContext(context.Background(), func(ctx context.Context) error {
+		_ = other.ExecuteContext(ctx, func(context.Context) error { return nil })
+
+		return outer.ExecuteContext(ctx, func(context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("ExecuteContext() error = %v", err)
+	}
+
+	if got := outer.Snapshot(); got.TotalRequests != 1 {
+		t.Errorf("outer snapshot = %+v, want the nested call counted once", got)
+	}
+
+	if got := other.Snapshot(); got.TotalRequests != 1 {
+		t.Errorf("other snapshot = %+v, want the call of another breaker counted", got)
+	}
+
+	ctx := circuitbreaker.WithBreaker(context.Background(), outer)
+	_ = outer.ExecuteContext(ctx, func(context.Context) error { return nil })
+	if got := outer.Snapshot(); got.TotalRequests != 1 {
+		t.Errorf("outer snapshot = %+v, want the call marked with WithBreaker not counted", got)
+	}
+}
+
+func TestNewCircuitBreakerInvalidWindow(t *testing.T) {
+	for _, window := range [][2]int{{0, 1}, {1, 0}, {-1, 10}} {
+		if _, err := circuitbreaker.NewCircuitBreaker(window[0], window[1], 0.5, stepInterval, nil); err == nil {
+			t.Errorf("NewCircuitBreaker(%d, %d) error = nil", window[0], window[1])
+		}
+	}
+}
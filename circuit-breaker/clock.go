@@ -0,0 +1,15 @@
+package circuitbreaker
+
+import "time"
+
+// Clock tells the CircuitBreaker the current time, every time based transition like the bucket rotation,
+// the stateStepInterval and the half-open ramp is measured with it.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
@@ -6,4 +6,11 @@ var (
 	ErrRateTooHigh      = errors.New("error rate too high")
 	ErrRequestDropped   = errors.New("request dropped early by circuit breaker")
 	ErrThresholdTooHigh = errors.New("threshold too high")
+
+	ErrTooManyConcurrent = errors.New("too many concurrent requests")
+	ErrUnknownState      = errors.New("unknown circuit breaker state")
+	ErrPanic             = errors.New("circuit breaker call panicked")
+
+	// errInvalidWindow rejects a window or buckets per second which is not positive
+	errInvalidWindow = errors.New("window and buckets per second must be positive")
 )
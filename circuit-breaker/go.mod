@@ -1,3 +1,3 @@
 module github.com/OZahed/bob/circuit-breaker
 
-go 1.16
+go 1.21
@@ -0,0 +1,47 @@
+package circuitbreaker
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var stateNames = map[State]string{
+	Closed:   "closed",
+	Open:     "open",
+	HalfOpen: "half-open",
+}
+
+func (s State) String() string {
+	if name, ok := stateNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// MarshalJSON encodes the state by its name, "closed", "open" or "half-open".
+func (s State) MarshalJSON() ([]byte, error) {
+	name, ok := stateNames[s]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownState, int(s))
+	}
+
+	return json.Marshal(name)
+}
+
+// UnmarshalJSON decodes a state encoded by MarshalJSON.
+func (s *State) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for state, n := range stateNames {
+		if n == name {
+			*s = state
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownState, name)
+}
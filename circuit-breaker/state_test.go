@@ -0,0 +1,58 @@
+package circuitbreaker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	circuitbreaker "github.com/OZahed/bob/circuit-breaker"
+)
+
+func TestStateJSON(t *testing.T) {
+	tests := []struct {
+		state circuitbreaker.State
+		json  string
+	}{
+		{state: circuitbreaker.Closed, json: `"closed"`},
+		{state: circuitbreaker.Open, json: `"open"`},
+		{state: circuitbreaker.HalfOpen, json: `"half-open"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state.String(), func(t *testing.T) {
+			data, err := json.Marshal(tt.state)
+			if err != nil || string(data) != tt.json {
+				t.Fatalf("Marshal() = %s, %v, want %s", data, err, tt.json)
+			}
+
+			var got circuitbreaker.State
+			if err := json.Unmarshal(data, &got); err != nil || got != tt.state {
+				t.Errorf("Unmarshal() = %v, %v, want %v", got, err, tt.state)
+			}
+		})
+	}
+
+	var s circuitbreaker.State
+	if err := json.Unmarshal([]byte(`"ajar"`), &s); !errors.Is(err, circuitbreaker.ErrUnknownState) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, circuitbreaker.ErrUnknownState)
+	}
+}
+
+func TestSnapshotJSON(t *testing.T) {
+	cb := newBreaker(t, 10, 1, 0.5, stepInterval, circuitbreaker.WithClock(newFakeClock()))
+
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(succeed)
+	_ = cb.Execute(fail)
+
+	data, err := json.Marshal(cb.Snapshot())
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := `{"state":"closed","failureRate":0.25,"totalRequests":4,"failedRequests":1,"droppedCount":0}`
+	if string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,44 @@
+package db
+
+import "log/slog"
+
+// argsKey is the key of the args attribute of the slow queries
+const argsKey = "args"
+
+// WithArgRedactor transforms the args of the slow queries before they are logged, to keep sensitive values like
+// emails out of the logs. redact gets a copy of the args, the args implementing slog.LogValuer already resolved,
+// and only runs for the queries which are logged.
+func WithArgRedactor(redact func(args []any) []any) Option {
+	return func(db *DB) {
+		db.redactArgs = redact
+	}
+}
+
+// logArgs is the slog.LogValuer of the args of a slow query, it defers the redaction until the query is logged
+type logArgs struct {
+	args   []any
+	redact func([]any) []any
+}
+
+func (a logArgs) LogValue() slog.Value {
+	args := make([]any, len(a.args))
+	for i, arg := range a.args {
+		// slog only resolves the LogValuer attributes, not the elements of a slice
+		if v, ok := arg.(slog.LogValuer); ok {
+			arg = v.LogValue().Resolve().Any()
+		}
+
+		args[i] = arg
+	}
+
+	if a.redact != nil {
+		args = a.redact(args)
+	}
+
+	return slog.AnyValue(args)
+}
+
+// argsAttr is the attribute of the args of a slow query
+func (db *DB) argsAttr(args []any) slog.Attr {
+	return slog.Any(argsKey, logArgs{args: args, redact: db.redactArgs})
+}
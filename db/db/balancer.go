@@ -3,6 +3,14 @@
 //
 // usage example:
 //
+//	cfg := db.Config{MaxOpen: 10}.WithConnectRetry(3, time.Second)
+//	loadBalancedDb, err := db.OpenBalanced(cfg, leaderConString, slaveConnectionStrings, driver)
+//	if err != nil {
+//		// do something
+//	}
+//
+// The nodes can also be opened one by one, for instance with otelsql:
+//
 //	leader,err := otelsql.Open(diver, conString)
 //	if err != nil {
 //		// do something
@@ -37,13 +45,26 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
+	"sort"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/OZahed/db/internal/helper"
 )
 
+// masterNode is the index of the master in pdbs
+const masterNode = 0
+
+// DB is used wherever a Database or a DatabaseX is, like the result of NewBalancedDB
+var (
+	_ Database  = (*DB)(nil)
+	_ DatabaseX = (*DB)(nil)
+)
+
 // DB is a logical database with multiple underlying physical databases
 // forming a single master multiple slaves topology.
 // Reads and writes are automatically directed to the correct physical db.
@@ -51,58 +72,114 @@ type DB struct {
 	SlowQueryThreshold time.Duration
 	pdbs               []Database  // Physical databases
 	xpdbs              []DatabaseX // Physical databases with sqlx extensions
+	xnodes             []int       // Index in pdbs of each of the xpdbs, guarded by mu
+	replicas           []int       // Index in pdbs of each of the slaves, guarded by mu
+	down               []uint32    // Set to 1 for the pdbs marked as unhealthy
+	nodeErrs           []error     // Last error of each of the unhealthy pdbs, guarded by mu
+	reads              []uint64    // Number of reads served by each of the pdbs
+	inflight           []int64     // Number of reads running on each of the pdbs, see DrainReplica
+	readPreference     ReadPreference
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
 	lg                 *slog.Logger
+	mu                 sync.RWMutex
+	explainHook        func(query string, role string)
+	clock              Clock
+
+	saturationInterval  time.Duration
+	saturationThreshold time.Duration
+	closed              chan struct{} // Closed by Close to stop the background work
+	closeOnce           sync.Once
+	promote             func(failed Database) (Database, error) // Set by WithMasterFailover
+	failoverMu          sync.Mutex                              // Held while promote runs
+	badConns            uint32                                  // Consecutive writes failing with a retryable error
+	maxSelectRows       int                                     // Rows Select scans before failing, zero is unlimited
+	isRetryable         func(error) bool                        // Set by WithErrorClassifier
+	redactArgs          func([]any) []any                       // Set by WithArgRedactor
+	slowSink            *slowSink                               // Set by WithSlowQuerySink
+	replicaZones        map[int]string                          // Zone of the pdbs, set by WithReplicaZones
+	localZone           string                                  // Set by WithLocalZone
+	localReplicas       []int                                   // The replicas in the localZone, guarded by mu
+	localXNodes         []int                                   // The xnodes in the localZone, guarded by mu
+	touches             touchMap                                // Keys written recently, see TouchWrite
 
 	count  uint64 // Monotonically incrementing counter on each query pdbs
 	countX uint64 // Monotonically incrementing counter on each query for xpdbs
 }
 
-// NewBalancedDB gets Database or DatabaseX interface, DatabaseX is a super set on Database Interface
+// NewBalancedDB gets Database or DatabaseX interface, DatabaseX is a super set on Database Interface.
+// Use NewDB to configure the balanced DB with options and NewValidatedDB to reject a nil master or slave.
 func NewBalancedDB(SlowQueryThreshold time.Duration, lg *slog.Logger, master Database, slaves ...Database) Database {
-	db := &DB{lg: lg}
+	return NewDB(master, slaves, WithSlowQueryThreshold(SlowQueryThreshold), WithLogger(lg))
+}
 
-	if SlowQueryThreshold > 0 {
-		db.SlowQueryThreshold = SlowQueryThreshold
-	}
+// Close closes all physical databases, master first and then the slaves concurrently,
+// releasing any open resources. Every database is closed even if some of them fail,
+// the returned error joins the failures of all nodes.
+func (db *DB) Close() error {
+	return db.CloseContext(context.Background())
+}
 
-	// check is salves are compatible with DatabaseX interface
-	for _, slave := range slaves {
-		if sx, ok := slave.(DatabaseX); ok {
-			db.xpdbs = append(db.xpdbs, sx)
-		}
-	}
+// CloseContext works like Close but stops waiting for the nodes when ctx is done.
+// The nodes which did not finish closing are reported in an error wrapping ctx.Err(),
+// their Close calls keep running in the background.
+func (db *DB) CloseContext(ctx context.Context) error {
+	db.closeOnce.Do(func() { close(db.closed) })
+
+	// release master first
+	masterErr := closeNodes(ctx, []Database{db.master()}, 0)
 
-	db.pdbs = append([]Database{master}, slaves...)
+	return errors.Join(masterErr, closeNodes(ctx, db.pdbs[1:], 1))
+}
 
-	return db
+type closeResult struct {
+	err  error
+	node int
 }
 
-// Close closes all physical databases concurrently after releasing master,
-// releasing any open resources.
-func (db *DB) Close() error {
-	// release master first
-	if err := db.master().Close(); err != nil {
-		return err
+// closeNodes closes nodes concurrently, offset is the index of the first node in pdbs used in the errors
+func closeNodes(ctx context.Context, nodes []Database, offset int) error {
+	results := make(chan closeResult, len(nodes))
+	for i := range nodes {
+		i := i
+		go func() {
+			results <- closeResult{node: offset + i, err: nodes[i].Close()}
+		}()
 	}
 
-	return helper.Scatter(len(db.pdbs), func(i int) error {
-		return db.pdbs[i].Close()
-	})
-}
+	pending := make(map[int]bool, len(nodes))
+	for i := range nodes {
+		pending[offset+i] = true
+	}
 
-// Begin starts a transaction on the master. The isolation level is dependent on the driver.
-func (db *DB) Begin() (*sql.Tx, error) {
-	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		tx, err := db.master().Begin()
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn("Slow query", slog.String("query", "BEGIN"), slog.Duration("duration", time.Since(start)))
+	var errs []error
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.node)
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("closing node %d: %w", r.node, r.err))
+			}
+		case <-ctx.Done():
+			unfinished := make([]int, 0, len(pending))
+			for node := range pending {
+				unfinished = append(unfinished, node)
+			}
+			sort.Ints(unfinished)
+
+			errs = append(errs, fmt.Errorf("closing nodes %v: %w", unfinished, ctx.Err()))
+			return errors.Join(errs...)
 		}
-
-		return tx, err
 	}
 
-	return db.master().Begin()
+	return errors.Join(errs...)
+}
+
+// Begin starts a transaction on the master. The isolation level is dependent on the driver.
+func (db *DB) Begin() (*sql.Tx, error) {
+	return onMaster(context.Background(), db, "BEGIN", func(master Database) (*sql.Tx, error) {
+		return master.Begin()
+	})
 }
 
 // BeginTx starts a transaction with the provided context on the master.
@@ -110,68 +187,66 @@ func (db *DB) Begin() (*sql.Tx, error) {
 // If a non-default isolation level is used that the driver doesn't support,
 // an error will be returned.
 func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
-	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		tx, err := db.master().BeginTx(ctx, opts)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn("Slow query", "query", "BEGIN(ctx)", slog.Duration("duration", time.Since(start)))
-		}
+	db.markWrite(ctx)
 
-		return tx, err
-	}
-
-	return db.master().BeginTx(ctx, opts)
+	return onMaster(ctx, db, "BEGIN", func(master Database) (*sql.Tx, error) {
+		return master.BeginTx(ctx, opts)
+	})
 }
 
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 // Exec uses the master as the underlying physical db.
 func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
-	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res, err := db.master().Exec(query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+	if db.writeTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), db.writeTimeout)
+		defer cancel()
 
-		return res, err
+		return db.ExecContext(ctx, query, args...)
 	}
 
-	return db.master().Exec(query, args...)
+	return onMaster(context.Background(), db, query, func(master Database) (sql.Result, error) {
+		return master.Exec(query, args...)
+	}, db.argsAttr(args))
 }
 
 // ExecContext executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 // Exec uses the master as the underlying physical db.
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query = tagQuery(ctx, query)
+	db.markWrite(ctx)
+
+	return onMaster(ctx, db, query, func(master Database) (sql.Result, error) {
+		return master.ExecContext(ctx, query, args...)
+	}, db.argsAttr(args))
+}
+
+// onMaster runs fn on the master of the moment, so the writes follow a failover, and counts its error toward the
+// failover, see observeMaster. query is passed to the explain hook and logged when it is slow, with attrs.
+func onMaster[T any](ctx context.Context, db *DB, query string, fn func(Database) (T, error), attrs ...any) (T, error) {
+	db.explain(query, RoleMaster)
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res, err := db.master().ExecContext(ctx, query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		res, err := fn(db.master())
+		db.observeMaster(err)
+		db.warnSlowQuery(ctx, start, query, masterNode, attrs...)
 
 		return res, err
 	}
 
-	return db.master().ExecContext(ctx, query, args...)
+	res, err := fn(db.master())
+	db.observeMaster(err)
+
+	return res, err
 }
 
 // Ping verifies if a connection to each physical database is still alive,
 // establishing a connection if necessary.
 func (db *DB) Ping() error {
 	return helper.Scatter(len(db.pdbs), func(i int) error {
-		return db.pdbs[i].Ping()
+		return db.node(i).Ping()
 	})
 }
 
@@ -179,86 +254,126 @@ func (db *DB) Ping() error {
 // alive, establishing a connection if necessary.
 func (db *DB) PingContext(ctx context.Context) error {
 	return helper.Scatter(len(db.pdbs), func(i int) error {
-		return db.pdbs[i].PingContext(ctx)
+		return db.node(i).PingContext(ctx)
+	})
+}
+
+// PingAll pings every physical database concurrently and returns the result of each, keyed by its index,
+// the master is 0 and the slaves follow in the order they were given. A nil error means the node is healthy.
+// It only reports the status, the read routing is left alone, use CheckHealth to act on it.
+func (db *DB) PingAll(ctx context.Context) map[int]error {
+	errs := make([]error, len(db.pdbs))
+	_ = helper.Scatter(len(db.pdbs), func(i int) error {
+		errs[i] = db.node(i).PingContext(ctx)
+		return nil
 	})
+
+	status := make(map[int]error, len(errs))
+	for i, err := range errs {
+		status[i] = err
+	}
+
+	return status
 }
 
-// TODO: Implement Prepare and PrepareContext
-// Prepare creates a prepared statement for later queries or executions
-// on each physical database, concurrently.
-// func (db *DB) Prepare(query string) (Stmt, error) {
-// 	stmts := make([]*sql.Stmt, len(db.pdbs))
+// CheckHealth works like PingAll and also marks every node healthy or unhealthy for the read routing by the result
+// of its ping, see SetHealthy. The ping errors of the unhealthy slaves are wrapped by ErrNoReplicasAvailable.
+func (db *DB) CheckHealth(ctx context.Context) map[int]error {
+	status := db.PingAll(ctx)
+	for i, err := range status {
+		db.setNodeError(i, err)
+	}
+
+	return status
+}
 
-// 	err := helper.Scatter(len(db.pdbs), func(i int) (err error) {
-// 		stmts[i], err = db.pdbs[i].Prepare(query)
-// 		return err
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
+// QueryAllReplicas runs query on every slave concurrently, ignoring the read preference and the health of the
+// slaves, and returns the rows of each slave in the order they were given. It is meant for diagnostics like
+// comparing the replicas to detect drift. When a slave fails the errors are joined and the rows of the other
+// slaves are closed. Without slaves it returns ErrNoReplicasAvailable.
+func (db *DB) QueryAllReplicas(ctx context.Context, query string, args ...interface{}) ([]*sql.Rows, error) {
+	db.mu.RLock()
+	replicas := db.replicas
+	for _, node := range replicas {
+		atomic.AddInt64(&db.inflight[node], 1)
+	}
+	db.mu.RUnlock()
 
-// 	return &stmt{db: db, stmts: stmts}, nil
-// }
+	if len(replicas) == 0 {
+		return nil, ErrNoReplicasAvailable
+	}
 
-// PrepareContext creates a prepared statement for later queries or executions
-// on each physical database, concurrently.
-//
-// The provided context is used for the preparation of the statement, not for
-// the execution of the statement.
-// func (db *DB) PrepareContext(ctx context.Context, query string) (Stmt, error) {
-// 	stmts := make([]*sql.Stmt, len(db.pdbs))
-
-// 	err := helper.Scatter(len(db.pdbs), func(i int) (err error) {
-// 		stmts[i], err = db.pdbs[i].PrepareContext(ctx, query)
-// 		return err
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return &stmt{db: db, stmts: stmts}, nil
-// }
+	// not ScatterCtx, its context is canceled once every query returns which would close the rows
+	rows, err := helper.ScatterCollect(len(replicas), func(i int) (*sql.Rows, error) {
+		node := replicas[i]
+		defer db.doneReading(node)
+		db.explain(query, nodeRole(node))
+
+		return db.node(node).QueryContext(ctx, query, args...)
+	})
+	if err != nil {
+		for _, r := range rows {
+			if r != nil {
+				_ = r.Close()
+			}
+		}
+
+		return nil, err
+	}
+
+	return rows, nil
+}
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 // Query uses a slave as the physical db.
 func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	node, pdb, err := db.slave(context.Background())
+	defer db.doneReading(node)
+	if err != nil {
+		return nil, err
+	}
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res, err := db.slave().Query(query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		res, err := pdb.Query(query, args...)
+		db.warnSlowQuery(context.Background(), start, query, node, db.argsAttr(args))
 
 		return res, err
 	}
-	return db.slave().Query(query, args...)
+	return pdb.Query(query, args...)
 }
 
 // QueryContext executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 // QueryContext uses a slave as the physical db.
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query = tagQuery(ctx, query)
+	node, pdb, err := db.slave(ctx)
+	defer db.doneReading(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.queryOn(ctx, node, pdb, query, args)
+}
+
+// queryOn runs the already tagged query on pdb, the node at index node in pdbs
+func (db *DB) queryOn(ctx context.Context, node int, pdb Database, query string, args []interface{}) (
+	*sql.Rows, error,
+) {
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res, err := db.slave().QueryContext(ctx, query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		res, err := pdb.QueryContext(ctx, query, args...)
+		db.warnSlowQuery(ctx, start, query, node, db.argsAttr(args))
 
 		return res, err
 	}
 
-	return db.slave().QueryContext(ctx, query, args...)
+	return pdb.QueryContext(ctx, query, args...)
 }
 
 // QueryRow executes a query that is expected to return at most one row.
@@ -266,22 +381,20 @@ func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{
 // Errors are deferred until Row's Scan method is called.
 // QueryRow uses a slave as the physical db.
 func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	// a Row can not carry the routing error, without a usable node the query runs on an unhealthy replica
+	node, pdb, _ := db.slave(context.Background())
+	defer db.doneReading(node)
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res := db.slave().QueryRow(query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		res := pdb.QueryRow(query, args...)
+		db.warnSlowQuery(context.Background(), start, query, node, db.argsAttr(args))
 
 		return res
 	}
 
-	return db.slave().QueryRow(query, args...)
+	return pdb.QueryRow(query, args...)
 }
 
 // QueryRowContext executes a query that is expected to return at most one row.
@@ -289,87 +402,299 @@ func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
 // Errors are deferred until Row's Scan method is called.
 // QueryRowContext uses a slave as the physical db.
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query = tagQuery(ctx, query)
+	// a Row can not carry the routing error, without a usable node the query runs on an unhealthy replica
+	node, pdb, _ := db.slave(ctx)
+	defer db.doneReading(node)
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		res := db.slave().QueryRowContext(ctx, query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		res := pdb.QueryRowContext(ctx, query, args...)
+		db.warnSlowQuery(ctx, start, query, node, db.argsAttr(args))
 
 		return res
 	}
 
-	return db.slave().QueryRowContext(ctx, query, args...)
+	return pdb.QueryRowContext(ctx, query, args...)
 }
 
-// Get
+// Get scans the single row returned by query on a slave into dest.
+// With WithDefaultReadTimeout the query is bounded by the timeout when the slave exposes GetContext, like sqlx.DB.
 func (db *DB) Get(dest interface{}, query string, args ...interface{}) error {
+	ctx := context.Background()
+	if db.readTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, db.readTimeout)
+		defer cancel()
+	}
+
+	node, xdb, err := db.slaveX(ctx)
+	defer db.doneReading(node)
+	if err != nil {
+		return err
+	}
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		err := db.slaveX().Get(dest, query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		err := getRow(ctx, xdb, dest, query, args)
+		db.warnSlowQuery(ctx, start, query, node, db.argsAttr(args))
 
 		return err
 	}
 
-	return db.slaveX().Get(dest, query, args...)
+	return getRow(ctx, xdb, dest, query, args)
 }
 
+// Select scans the rows returned by query on a slave into dest, a pointer to a slice.
+// With WithMaxSelectRows it fails with ErrTooManyRows as soon as the query returns more rows than the limit.
+// With WithDefaultReadTimeout the query is bounded by the timeout when the slave exposes SelectContext or
+// QueryxContext, like sqlx.DB.
 func (db *DB) Select(dest interface{}, query string, args ...interface{}) error {
+	ctx := context.Background()
+	if db.readTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, db.readTimeout)
+		defer cancel()
+	}
+
+	node, xdb, err := db.slaveX(ctx)
+	defer db.doneReading(node)
+	if err != nil {
+		return err
+	}
+	db.explain(query, nodeRole(node))
+
 	if db.SlowQueryThreshold > 0 {
-		start := time.Now()
-		err := db.slaveX().Select(dest, query, args...)
-		if time.Since(start) > db.SlowQueryThreshold {
-			db.lg.Warn(
-				"Slow query",
-				slog.Duration("duration", time.Since(start)),
-				slog.String("query", query),
-				slog.Any("args", args),
-			)
-		}
+		start := db.clock.Now()
+		err := db.selectRows(ctx, xdb, dest, query, args...)
+		db.warnSlowQuery(ctx, start, query, node, db.argsAttr(args))
 
 		return err
 	}
 
-	return db.slaveX().Select(dest, query, args...)
+	return db.selectRows(ctx, xdb, dest, query, args...)
+}
+
+// RoutingStats returns the number of reads each physical database served since construction,
+// keyed by its index, the master is 0 and the slaves follow in the order they were given.
+func (db *DB) RoutingStats() map[int]uint64 {
+	stats := make(map[int]uint64, len(db.reads))
+	for i := range db.reads {
+		stats[i] = atomic.LoadUint64(&db.reads[i])
+	}
+
+	return stats
+}
+
+// ResetCounters zeroes the round-robin counters and the RoutingStats, so the read distribution can be measured
+// again from a new baseline. Reads running concurrently keep picking valid nodes, each counter is reset atomically.
+func (db *DB) ResetCounters() {
+	atomic.StoreUint64(&db.count, 0)
+	atomic.StoreUint64(&db.countX, 0)
+
+	for i := range db.reads {
+		atomic.StoreUint64(&db.reads[i], 0)
+	}
+}
+
+// SetLogger replaces the logger used for slow queries, a nil logger disables the slow query logs.
+func (db *DB) SetLogger(lg *slog.Logger) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.lg = lg
+}
+
+func (db *DB) logger() *slog.Logger {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.lg
+}
+
+// warnSlowQuery logs the query when it took longer than SlowQueryThreshold with the index in pdbs of the node
+// it ran on and its role. The logger of ctx, see ContextWithLogger, is preferred over the logger of the DB.
+// The query is also written to the sink of WithSlowQuerySink.
+func (db *DB) warnSlowQuery(ctx context.Context, start time.Time, query string, node int, attrs ...any) {
+	duration := db.clock.Now().Sub(start)
+	if duration <= db.SlowQueryThreshold {
+		return
+	}
+
+	if db.slowSink != nil {
+		db.slowSink.write(slowRecord{
+			Time:       start,
+			Query:      query,
+			ArgsDigest: argsDigest(attrs),
+			DurationMS: float64(duration) / float64(time.Millisecond),
+			Role:       nodeRole(node),
+			Node:       node,
+		})
+	}
+
+	lg := LoggerFromContext(ctx)
+	if lg == nil {
+		lg = db.logger()
+	}
+
+	if lg == nil {
+		return
+	}
+
+	lg.Warn("Slow query", append([]any{
+		slog.Duration("duration", duration),
+		slog.String("query", query),
+		slog.String("role", nodeRole(node)),
+		slog.Int("node", node),
+	}, attrs...)...)
 }
 
 // master returns the master physical database
 func (db *DB) master() Database {
-	return db.pdbs[0]
+	return db.node(masterNode)
+}
+
+// node returns the physical database at index i of pdbs, the master can be swapped by WithMasterFailover
+func (db *DB) node(i int) Database {
+	if db.promote == nil {
+		return db.pdbs[i]
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.pdbs[i]
 }
 
-// slave returns one of the physical databases which is a slave
-func (db *DB) slave() Database {
-	return db.pdbs[db.acquireSlave(len(db.pdbs))]
+// slave returns the physical database serving the next read following the read preference and its index in pdbs.
+// When no node can serve the read it still returns the replica picked by the round-robin, for the callers which can
+// not report an error, with an error wrapping ErrNoReplicasAvailable. The read is counted as running on the node
+// until doneReading, which the callers defer even when there is an error.
+func (db *DB) slave(ctx context.Context) (int, Database, error) {
+	idx, err := db.slaveIdx(ctx)
+	return idx, db.node(idx), err
 }
 
-func (db *DB) slaveX() DatabaseX {
-	return db.xpdbs[db.acquireSlaveX(len(db.xpdbs))]
+// slaveIdx picks the index in pdbs of the node serving the next read,
+// without slaves the reads go to the master.
+func (db *DB) slaveIdx(ctx context.Context) (int, error) {
+	// the node is picked and its read counted under the lock, so DrainReplica sees every read routed to it
+	db.mu.RLock()
+	replicas := db.replicas
+	idx, ok := db.readNode(ctx, replicas, db.localReplicas, &db.count, true)
+	atomic.AddInt64(&db.inflight[idx], 1)
+	db.mu.RUnlock()
+
+	if !ok {
+		return idx, db.noReplicasError(replicas)
+	}
+	atomic.AddUint64(&db.reads[idx], 1)
+
+	return idx, nil
 }
 
-func (db *DB) acquireSlaveX(n int) int {
-	if n <= 1 {
-		return 0
+// slaveX works like slave for the sqlx compatible nodes, the returned DatabaseX is nil when there is none.
+func (db *DB) slaveX(ctx context.Context) (int, DatabaseX, error) {
+	_, masterX := db.master().(DatabaseX)
+
+	db.mu.RLock()
+	xnodes := db.xnodes
+	if len(xnodes) == 0 && !masterX {
+		atomic.AddInt64(&db.inflight[masterNode], 1)
+		db.mu.RUnlock()
+
+		return masterNode, nil, ErrNotSQLXCompatible
+	}
+
+	idx, ok := db.readNode(ctx, xnodes, db.localXNodes, &db.countX, masterX)
+	atomic.AddInt64(&db.inflight[idx], 1)
+	db.mu.RUnlock()
+
+	if !ok {
+		return idx, db.node(idx).(DatabaseX), db.noReplicasError(xnodes)
 	}
-	return int(1 + (atomic.AddUint64(&db.countX, 1) % uint64(n-1)))
+	atomic.AddUint64(&db.reads[idx], 1)
+
+	return idx, db.node(idx).(DatabaseX), nil
+}
+
+// doneReading ends a read counted by slave, slaveIdx, slaveX or retryNode
+func (db *DB) doneReading(node int) {
+	atomic.AddInt64(&db.inflight[node], -1)
+}
+
+// sqlxReadable reports whether a sqlx read can be served, by a sqlx compatible slave or master
+func (db *DB) sqlxReadable() bool {
+	_, masterX := db.master().(DatabaseX)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return len(db.xnodes) > 0 || masterX
 }
 
-func (db *DB) acquireSlave(n int) int {
-	if n <= 1 {
-		return 0
+// readNode picks the index in pdbs of the node serving the next read out of the master and the replicas,
+// useMaster tells if the master can serve the read, it serves the reads of a context sticking to it, see
+// StickToMasterFor, and the reads of a key touched by TouchWrite. A replica read with an affinity key in ctx goes
+// to the replica the key hashes to while it is healthy, see WithAffinityKey. The healthy local replicas, the ones
+// in the zone of the process, are preferred over the others, see WithLocalZone. It returns false when the read
+// preference does not allow to fall back to the master and none of the replicas is healthy.
+func (db *DB) readNode(ctx context.Context, replicas, local []int, counter *uint64, useMaster bool) (int, bool) {
+	if useMaster && (db.stickToMaster(ctx) || db.touched(ctx)) {
+		return masterNode, true
 	}
-	return int(1 + (atomic.AddUint64(&db.count, 1) % uint64(n-1)))
+
+	switch db.readPreference {
+	case Primary:
+		if useMaster {
+			return masterNode, true
+		}
+	case PrimaryPreferred:
+		if useMaster && db.isHealthy(masterNode) {
+			return masterNode, true
+		}
+	}
+
+	if len(replicas) == 0 {
+		return masterNode, true
+	}
+
+	if key, ok := affinityKey(ctx); ok {
+		if idx := affinityReplica(key, replicas); db.isHealthy(idx) {
+			return idx, true
+		}
+	}
+
+	if len(local) > 0 {
+		if idx, healthy := db.nextReplica(local, counter); healthy {
+			return idx, true
+		}
+	}
+
+	idx, healthy := db.nextReplica(replicas, counter)
+	if healthy {
+		return idx, true
+	}
+
+	if useMaster && db.readPreference != Secondary {
+		return masterNode, true
+	}
+
+	return idx, false
+}
+
+// nextReplica returns the next healthy replica in round-robin order, when none of them is healthy it returns
+// the replica the round-robin picked and false.
+func (db *DB) nextReplica(replicas []int, counter *uint64) (int, bool) {
+	n := uint64(len(replicas))
+	start := atomic.AddUint64(counter, 1)
+	for i := uint64(0); i < n; i++ {
+		if idx := replicas[(start+i)%n]; db.isHealthy(idx) {
+			return idx, true
+		}
+	}
+
+	return replicas[start%n], false
 }
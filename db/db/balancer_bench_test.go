@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+// The selection does not allocate. Padding count and countX onto separate cache lines made no difference
+// on the machine these numbers were taken on, a single core can not show false sharing, so the counters stay
+// adjacent until a multi-core run of BenchmarkSlaveMixedParallel shows they contend:
+//
+//	                              adjacent      padded
+//	BenchmarkSlaveParallel        27.7 ns/op    30.6 ns/op    0 allocs/op
+//	BenchmarkSlaveXParallel       31.8 ns/op    31.9 ns/op    0 allocs/op
+//	BenchmarkSlaveMixedParallel   31.7 ns/op    31.5 ns/op    0 allocs/op
+func newBenchmarkDB() *DB {
+	slaves := make([]Database, 4)
+	for i := range slaves {
+		slaves[i] = &sqlxDB{}
+	}
+
+	return NewDB(&sqlxDB{}, slaves)
+}
+
+func BenchmarkSlaveParallel(b *testing.B) {
+	db := newBenchmarkDB()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _, _ = db.slave(ctx)
+		}
+	})
+}
+
+func BenchmarkSlaveXParallel(b *testing.B) {
+	db := newBenchmarkDB()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _, _ = db.slaveX(ctx)
+		}
+	})
+}
+
+// BenchmarkSlaveMixedParallel selects with both counters at once, it exposes false sharing between them
+func BenchmarkSlaveMixedParallel(b *testing.B) {
+	db := newBenchmarkDB()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		x := false
+		for pb.Next() {
+			if x {
+				_, _, _ = db.slaveX(ctx)
+			} else {
+				_, _, _ = db.slave(ctx)
+			}
+			x = !x
+		}
+	})
+}
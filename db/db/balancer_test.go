@@ -0,0 +1,736 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestCloseClosesAllNodes(t *testing.T) {
+	errClose := errors.New("close failed")
+	master := &fakeDB{closeErr: errClose}
+	slaves := []*fakeDB{{}, {closeErr: errClose}, {}}
+
+	balanced := db.NewBalancedDB(0, nil, master, slaves[0], slaves[1], slaves[2])
+
+	err := balanced.Close()
+	if !errors.Is(err, errClose) {
+		t.Fatalf("Close() error = %v, want %v", err, errClose)
+	}
+
+	if !strings.Contains(err.Error(), "node 0") || !strings.Contains(err.Error(), "node 2") {
+		t.Errorf("Close() error = %v, want the failed nodes 0 and 2", err)
+	}
+
+	if master.closed != 1 {
+		t.Errorf("master closed %d times, want 1", master.closed)
+	}
+
+	for i, slave := range slaves {
+		if slave.closed != 1 {
+			t.Errorf("slave %d closed %d times, want 1", i, slave.closed)
+		}
+	}
+}
+
+func TestCloseContextAbandonsHangingNodes(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+
+	balanced := db.NewBalancedDB(0, nil, &fakeDB{}, &fakeDB{}, &fakeDB{closeWait: hang})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := balanced.(*db.DB).CloseContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("CloseContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if !strings.Contains(err.Error(), "[2]") {
+		t.Errorf("CloseContext() error = %v, want the hanging node 2", err)
+	}
+}
+
+func TestGetReaderAndWriter(t *testing.T) {
+	ctx := context.Background()
+	master, slave := &fakeDB{}, &fakeDB{}
+	balanced := db.NewBalancedDB(0, nil, master, slave).(*db.DB)
+
+	r := balanced.GetReader()
+	_, _ = r.Query("SELECT 1")
+	_, _ = r.QueryContext(ctx, "SELECT 1")
+	_ = r.QueryRow("SELECT 1")
+	_ = r.QueryRowContext(ctx, "SELECT 1")
+
+	w := balanced.GetWriter()
+	_, _ = w.Begin()
+	_, _ = w.BeginTx(ctx, nil)
+	_, _ = w.Exec("DELETE FROM t")
+	_, _ = w.ExecContext(ctx, "DELETE FROM t")
+
+	if master.reads != 0 || master.writes != 4 {
+		t.Errorf("master reads/writes = %d/%d, want 0/4", master.reads, master.writes)
+	}
+
+	if slave.reads != 4 || slave.writes != 0 {
+		t.Errorf("slave reads/writes = %d/%d, want 4/0", slave.reads, slave.writes)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	master, slave := &fakeDB{}, &fakeDB{}
+
+	r := db.ReadOnly(db.NewBalancedDB(0, nil, master, slave))
+	if _, ok := r.(db.WriteQuerier); ok {
+		t.Fatal("ReadOnly() handle can be used as a WriteQuerier")
+	}
+
+	_, _ = r.Query("SELECT 1")
+	if master.reads != 0 || slave.reads != 1 {
+		t.Errorf("master/slave reads = %d/%d, want 0/1", master.reads, slave.reads)
+	}
+}
+
+func TestSlowQueryLogger(t *testing.T) {
+	balanced := db.NewBalancedDB(time.Nanosecond, nil, &fakeDB{}, &fakeDB{}).(*db.DB)
+
+	// a nil logger must not panic when a slow query fires
+	_, _ = balanced.Query("SELECT 1")
+
+	buf := &bytes.Buffer{}
+	balanced.SetLogger(slog.New(slog.NewTextHandler(buf, nil)))
+	_, _ = balanced.Exec("DELETE FROM t")
+
+	if !strings.Contains(buf.String(), "Slow query") || !strings.Contains(buf.String(), "DELETE FROM t") {
+		t.Errorf("slow query log = %q, want the slow query", buf.String())
+	}
+}
+
+// secret is an arg which redacts itself in the logs
+type secret string
+
+func (secret) LogValue() slog.Value { return slog.StringValue("***") }
+
+func TestArgRedactor(t *testing.T) {
+	buf := &bytes.Buffer{}
+	balanced := db.NewDB(&fakeDB{}, nil,
+		db.WithSlowQueryThreshold(time.Nanosecond),
+		db.WithLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+		db.WithArgRedactor(func(args []any) []any {
+			for i, arg := range args {
+				if s, ok := arg.(string); ok && len(s) > 3 {
+					args[i] = s[:2] + "..."
+				}
+			}
+
+			return args
+		}),
+	)
+
+	args := []any{"alice@example.com", secret("hunter2"), 42}
+	_, _ = balanced.Exec("INSERT INTO users VALUES (?, ?, ?)", args...)
+
+	if want := `"args":["al...","***",42]`; !strings.Contains(buf.String(), want) {
+		t.Errorf("slow query log = %q, want %s", buf.String(), want)
+	}
+
+	if args[0] != "alice@example.com" {
+		t.Errorf("args[0] = %v, the redactor changed the args of the query", args[0])
+	}
+}
+
+func TestSlowQuerySink(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	buf := &bytes.Buffer{}
+	balanced := db.NewDB(&fakeDB{}, []db.Database{&slowDB{clock: clock, took: 100 * time.Millisecond}},
+		db.WithSlowQueryThreshold(50*time.Millisecond),
+		db.WithSlowQuerySink(buf),
+		db.WithClock(clock),
+	)
+
+	_, _ = balanced.Query("SELECT * FROM users WHERE email = ?", "alice@example.com")
+	// the master answers instantly
+	_, _ = balanced.Exec("DELETE FROM t")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("sink lines = %q, want only the slow query", lines)
+	}
+
+	var rec struct {
+		Time       time.Time `json:"time"`
+		Query      string    `json:"query"`
+		ArgsDigest string    `json:"args_digest"`
+		DurationMS float64   `json:"duration_ms"`
+		Role       string    `json:"role"`
+		Node       int       `json:"node"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("sink line %q is not JSON: %v", lines[0], err)
+	}
+
+	if rec.Query != "SELECT * FROM users WHERE email = ?" || rec.DurationMS != 100 || rec.Role != db.RoleSlave ||
+		rec.Node != 1 || !rec.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("sink record = %+v", rec)
+	}
+
+	if rec.ArgsDigest == "" || strings.Contains(lines[0], "alice") {
+		t.Errorf("sink line = %q, want a digest of the args instead of the args", lines[0])
+	}
+}
+
+func TestSlowQueryDuration(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	buf := &bytes.Buffer{}
+	balanced := db.NewDB(&fakeDB{}, []db.Database{&slowDB{clock: clock, took: 100 * time.Millisecond}},
+		db.WithSlowQueryThreshold(50*time.Millisecond),
+		db.WithLogger(slog.New(slog.NewJSONHandler(buf, nil))),
+		db.WithClock(clock),
+	)
+
+	_, _ = balanced.Query("SELECT 1")
+	// the master answers instantly
+	_, _ = balanced.Exec("DELETE FROM t")
+
+	var record struct {
+		Msg      string        `json:"msg"`
+		Query    string        `json:"query"`
+		Duration time.Duration `json:"duration"`
+		Role     string        `json:"role"`
+		Node     int           `json:"node"`
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("slow query logs = %q, want exactly one", lines)
+	}
+
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if record.Msg != "Slow query" || record.Query != "SELECT 1" || record.Duration != 100*time.Millisecond {
+		t.Errorf("slow query log = %+v, want SELECT 1 taking 100ms", record)
+	}
+
+	if record.Role != db.RoleSlave || record.Node != 1 {
+		t.Errorf("slow query log role/node = %s/%d, want slave/1", record.Role, record.Node)
+	}
+}
+
+func TestSlowQueryContextLogger(t *testing.T) {
+	dbBuf, reqBuf := &bytes.Buffer{}, &bytes.Buffer{}
+	balanced := db.NewDB(&fakeDB{}, []db.Database{&fakeDB{}},
+		db.WithSlowQueryThreshold(time.Nanosecond),
+		db.WithLogger(slog.New(slog.NewTextHandler(dbBuf, nil))),
+	)
+
+	reqLogger := slog.New(slog.NewTextHandler(reqBuf, nil)).With(slog.String("traceID", "abc123"))
+	ctx := db.ContextWithLogger(context.Background(), reqLogger)
+
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	if !strings.Contains(reqBuf.String(), "traceID=abc123") || dbBuf.Len() != 0 {
+		t.Errorf("request log = %q, db log = %q, want the slow query on the request logger", reqBuf, dbBuf)
+	}
+
+	_, _ = balanced.ExecContext(context.Background(), "DELETE FROM t")
+	if !strings.Contains(dbBuf.String(), "DELETE FROM t") {
+		t.Errorf("db log = %q, want the fallback to the db logger", dbBuf)
+	}
+}
+
+func TestRoutingStats(t *testing.T) {
+	balanced := db.NewBalancedDB(0, nil, &fakeDB{}, &fakeDB{}, &fakeDB{}, &fakeDB{}).(*db.DB)
+
+	for i := 0; i < 30; i++ {
+		_, _ = balanced.Query("SELECT 1")
+	}
+	_, _ = balanced.Exec("DELETE FROM t")
+
+	want := map[int]uint64{0: 0, 1: 10, 2: 10, 3: 10}
+	if got := balanced.RoutingStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RoutingStats() = %v, want %v", got, want)
+	}
+}
+
+func TestAffinityKey(t *testing.T) {
+	balanced := db.NewDB(&fakeDB{}, []db.Database{&fakeDB{}, &fakeDB{}, &fakeDB{}})
+	ctx := db.WithAffinityKey(context.Background(), "user-1")
+
+	for i := 0; i < 10; i++ {
+		_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	}
+
+	node := -1
+	for n, reads := range balanced.RoutingStats() {
+		if reads == 10 {
+			node = n
+		}
+	}
+	if node < 1 {
+		t.Fatalf("RoutingStats() = %v, want every read of the key on one replica", balanced.RoutingStats())
+	}
+
+	balanced.ResetCounters()
+	balanced.SetHealthy(node, false)
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	if got := balanced.RoutingStats()[node]; got != 0 {
+		t.Errorf("unhealthy replica %d served %d reads, want the round-robin fallback", node, got)
+	}
+}
+
+func TestResetCounters(t *testing.T) {
+	balanced := db.NewBalancedDB(0, nil, &fakeDB{}, &fakeDB{}, &fakeDB{}).(*db.DB)
+
+	for i := 0; i < 5; i++ {
+		_, _ = balanced.Query("SELECT 1")
+	}
+
+	balanced.ResetCounters()
+
+	want := map[int]uint64{0: 0, 1: 0, 2: 0}
+	if got := balanced.RoutingStats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RoutingStats() after ResetCounters() = %v, want %v", got, want)
+	}
+}
+
+func TestResetCountersDuringReads(t *testing.T) {
+	slaves := make([]db.Database, 3)
+	for i := range slaves {
+		slaves[i] = &readCounter{}
+	}
+	balanced := db.NewDB(&readCounter{}, slaves)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_, _ = balanced.Query("SELECT 1")
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		balanced.ResetCounters()
+	}
+	wg.Wait()
+}
+
+func TestReadsWithoutSlavesUseMaster(t *testing.T) {
+	ctx := context.Background()
+	master := &fakeDB{}
+	balanced := db.NewBalancedDB(0, nil, master).(*db.DB)
+
+	for i := 0; i < 5; i++ {
+		_ = balanced.QueryRow("SELECT 1")
+		_ = balanced.QueryRowContext(ctx, "SELECT 1")
+		_, _ = balanced.Query("SELECT 1")
+	}
+
+	if master.reads != 15 {
+		t.Errorf("master reads = %d, want 15", master.reads)
+	}
+
+	if got := balanced.RoutingStats(); got[0] != 15 {
+		t.Errorf("RoutingStats() = %v, want every read on the master", got)
+	}
+}
+
+func TestPingAll(t *testing.T) {
+	errDown := errors.New("connection refused")
+	master, down := &fakeDB{}, &fakeDB{pingErr: errDown}
+	balanced := db.NewDB(master, []db.Database{&fakeDB{}, down},
+		db.WithReadPreference(db.SecondaryPreferred))
+
+	status := balanced.PingAll(context.Background())
+
+	want := map[int]error{0: nil, 1: nil, 2: errDown}
+	if !reflect.DeepEqual(status, want) {
+		t.Fatalf("PingAll() = %v, want %v", status, want)
+	}
+
+	// PingAll leaves the routing alone
+	for i := 0; i < 4; i++ {
+		_, _ = balanced.Query("SELECT 1")
+	}
+
+	if down.reads != 2 {
+		t.Errorf("slave served %d reads after PingAll, want 2", down.reads)
+	}
+
+	if status := balanced.CheckHealth(context.Background()); !reflect.DeepEqual(status, want) {
+		t.Fatalf("CheckHealth() = %v, want %v", status, want)
+	}
+
+	// the unhealthy node is skipped by the reads
+	for i := 0; i < 4; i++ {
+		_, _ = balanced.Query("SELECT 1")
+	}
+
+	if down.reads != 2 {
+		t.Errorf("unhealthy slave served %d reads after CheckHealth, want none", down.reads-2)
+	}
+}
+
+func TestDefaultTimeouts(t *testing.T) {
+	master, slave := &deadlineDB{}, &deadlineDB{}
+	balanced := db.NewDB(master, []db.Database{slave},
+		db.WithDefaultReadTimeout(time.Second),
+		db.WithDefaultWriteTimeout(2*time.Second),
+	)
+
+	reads := []struct {
+		name string
+		read func()
+	}{
+		{name: "Get", read: func() { _ = balanced.Get(new(int), "SELECT 1") }},
+		{name: "Select", read: func() { _ = balanced.Select(new([]int), "SELECT 1") }},
+	}
+	for _, r := range reads {
+		r.read()
+		if slave.timeout <= 0 || slave.timeout > time.Second {
+			t.Errorf("%s() timeout = %v, want at most 1s", r.name, slave.timeout)
+		}
+
+		if slave.ctx.Err() == nil {
+			t.Errorf("%s() left the read timeout running", r.name)
+		}
+	}
+
+	// the rows of Query and QueryRow outlive the call, they are not bounded
+	_, _ = balanced.Query("SELECT 1")
+	if slave.timeout != 0 {
+		t.Errorf("Query() timeout = %v, want none", slave.timeout)
+	}
+
+	_ = balanced.QueryRow("SELECT 1")
+	if slave.timeout != 0 {
+		t.Errorf("QueryRow() timeout = %v, want none", slave.timeout)
+	}
+
+	_, _ = balanced.Exec("DELETE FROM t")
+	if master.timeout <= time.Second || master.timeout > 2*time.Second {
+		t.Errorf("Exec() timeout = %v, want at most 2s", master.timeout)
+	}
+
+	// the context methods keep the caller's context
+	_, _ = balanced.QueryContext(context.Background(), "SELECT 1")
+	if slave.timeout != 0 {
+		t.Errorf("QueryContext() timeout = %v, want none", slave.timeout)
+	}
+}
+
+// fakeDB is a Database which records the calls made to it
+type fakeDB struct {
+	closeErr  error
+	pingErr   error
+	execErr   error
+	closeWait chan struct{}
+	closed    int
+	reads     int
+	writes    int
+}
+
+func (f *fakeDB) Close() error {
+	if f.closeWait != nil {
+		<-f.closeWait
+	}
+
+	f.closed++
+	return f.closeErr
+}
+
+func (f *fakeDB) Ping() error                         { return f.pingErr }
+func (f *fakeDB) PingContext(_ context.Context) error { return f.pingErr }
+
+func (f *fakeDB) Begin() (*sql.Tx, error) {
+	f.writes++
+	return nil, nil
+}
+
+func (f *fakeDB) BeginTx(_ context.Context, _ *sql.TxOptions) (*sql.Tx, error) {
+	f.writes++
+	return nil, nil
+}
+
+func (f *fakeDB) Exec(_ string, _ ...interface{}) (sql.Result, error) {
+	f.writes++
+	return nil, f.execErr
+}
+
+func (f *fakeDB) ExecContext(_ context.Context, _ string, _ ...interface{}) (sql.Result, error) {
+	f.writes++
+	return nil, f.execErr
+}
+
+func (f *fakeDB) Query(_ string, _ ...interface{}) (*sql.Rows, error) {
+	f.reads++
+	return nil, nil
+}
+
+func (f *fakeDB) QueryContext(_ context.Context, _ string, _ ...interface{}) (*sql.Rows, error) {
+	f.reads++
+	return nil, nil
+}
+
+func (f *fakeDB) QueryRow(_ string, _ ...interface{}) *sql.Row {
+	f.reads++
+	return nil
+}
+
+func (f *fakeDB) QueryRowContext(_ context.Context, _ string, _ ...interface{}) *sql.Row {
+	f.reads++
+	return nil
+}
+
+// readCounter is a fakeDB which can be read from concurrently
+type readCounter struct {
+	fakeDB
+	n int64
+}
+
+func (r *readCounter) Query(_ string, _ ...interface{}) (*sql.Rows, error) {
+	atomic.AddInt64(&r.n, 1)
+	return nil, nil
+}
+
+// queryRecorder is a fakeDB which records the last query it got
+type queryRecorder struct {
+	fakeDB
+	last string
+}
+
+func (q *queryRecorder) QueryContext(_ context.Context, query string, _ ...interface{}) (*sql.Rows, error) {
+	q.last = query
+	return nil, nil
+}
+
+func (q *queryRecorder) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	q.last = query
+	return nil, nil
+}
+
+// fakeClock is a db.Clock which only moves when a slowDB is queried
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// slowDB is a fakeDB whose queries take took on the fake clock
+type slowDB struct {
+	fakeDB
+	clock *fakeClock
+	took  time.Duration
+}
+
+func (s *slowDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	s.clock.now = s.clock.now.Add(s.took)
+	return s.fakeDB.Query(query, args...)
+}
+
+// deadlineDB is a fakeDB which records the time left until the deadline of the context it got
+type deadlineDB struct {
+	fakeDB
+	timeout time.Duration
+	ctx     context.Context
+}
+
+func (d *deadlineDB) record(ctx context.Context) {
+	d.ctx = ctx
+	d.timeout = 0
+	if deadline, ok := ctx.Deadline(); ok {
+		d.timeout = time.Until(deadline)
+	}
+}
+
+func (d *deadlineDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	d.record(context.Background())
+	return d.fakeDB.Query(query, args...)
+}
+
+func (d *deadlineDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	d.record(context.Background())
+	return d.fakeDB.QueryRow(query, args...)
+}
+
+func (d *deadlineDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	d.record(ctx)
+	return d.fakeDB.QueryRowContext(ctx, query, args...)
+}
+
+func (d *deadlineDB) Get(_ interface{}, _ string, _ ...interface{}) error {
+	d.record(context.Background())
+	return nil
+}
+
+func (d *deadlineDB) Select(_ interface{}, _ string, _ ...interface{}) error {
+	d.record(context.Background())
+	return nil
+}
+
+func (d *deadlineDB) GetContext(ctx context.Context, _ interface{}, _ string, _ ...interface{}) error {
+	d.record(ctx)
+	return nil
+}
+
+func (d *deadlineDB) SelectContext(ctx context.Context, _ interface{}, _ string, _ ...interface{}) error {
+	d.record(ctx)
+	return nil
+}
+
+func (d *deadlineDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	d.record(ctx)
+	return d.fakeDB.QueryContext(ctx, query, args...)
+}
+
+func (d *deadlineDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	d.record(ctx)
+	return d.fakeDB.ExecContext(ctx, query, args...)
+}
+
+func TestNewValidatedDB(t *testing.T) {
+	var nilDB *fakeDB
+
+	tests := []struct {
+		name    string
+		master  db.Database
+		slaves  []db.Database
+		wantErr error
+	}{
+		{name: "valid", master: &fakeDB{}, slaves: []db.Database{&fakeDB{}}},
+		{name: "nil master", slaves: []db.Database{&fakeDB{}}, wantErr: db.ErrNilMaster},
+		{name: "typed nil master", master: nilDB, wantErr: db.ErrNilMaster},
+		{name: "nil slave", master: &fakeDB{}, slaves: []db.Database{&fakeDB{}, nil}, wantErr: db.ErrNilReplica},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balanced, err := db.NewValidatedDB(tt.master, tt.slaves)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewValidatedDB() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if (balanced == nil) != (tt.wantErr != nil) {
+				t.Errorf("NewValidatedDB() = %v, want a DB only without error", balanced)
+			}
+		})
+	}
+}
+
+func TestQueryAllReplicas(t *testing.T) {
+	ctx := context.Background()
+	slaves := []db.DatabaseX{newSQLiteDB(t), newSQLiteDB(t)}
+	if _, err := slaves[0].Exec("INSERT INTO users (name, age) VALUES ('alice', 30)"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	balanced := db.NewDB(newSQLiteDB(t), []db.Database{slaves[0], slaves[1]})
+
+	all, err := balanced.QueryAllReplicas(ctx, "SELECT COUNT(*) FROM users")
+	if err != nil {
+		t.Fatalf("QueryAllReplicas() error = %v", err)
+	}
+
+	var counts []int
+	for _, rows := range all {
+		var n int
+		for rows.Next() {
+			_ = rows.Scan(&n)
+		}
+		_ = rows.Close()
+		counts = append(counts, n)
+	}
+
+	if want := []int{1, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts = %v, want %v, the drift of the first replica", counts, want)
+	}
+
+	if _, err := balanced.QueryAllReplicas(ctx, "SELECT * FROM missing"); err == nil {
+		t.Error("QueryAllReplicas() of a missing table error = nil")
+	}
+
+	master := db.NewDB(&fakeDB{}, nil)
+	if _, err := master.QueryAllReplicas(ctx, "SELECT 1"); !errors.Is(err, db.ErrNoReplicasAvailable) {
+		t.Errorf("QueryAllReplicas() without slaves error = %v, want %v", err, db.ErrNoReplicasAvailable)
+	}
+}
+
+func TestRandomStart(t *testing.T) {
+	first := map[int]bool{}
+	for i := 0; i < 50; i++ {
+		balanced := db.NewDB(&fakeDB{}, []db.Database{&fakeDB{}, &fakeDB{}}, db.WithRandomStart(true))
+		_, _ = balanced.Query("SELECT 1")
+
+		for node, reads := range balanced.RoutingStats() {
+			if reads > 0 {
+				first[node] = true
+			}
+		}
+	}
+
+	if !first[1] || !first[2] {
+		t.Errorf("first reads went to %v, want both replicas", first)
+	}
+}
+
+func TestQueryTag(t *testing.T) {
+	master, slave := &queryRecorder{}, &queryRecorder{}
+	balanced := db.NewDB(master, []db.Database{slave})
+
+	ctx := db.WithQueryTag(context.Background(), map[string]string{"route": "/users", "controller": "it's */"})
+	ctx = db.WithQueryTag(ctx, map[string]string{"action": "list users"})
+
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	want := "/*action='list%20users',controller='it%27s%20%2A%2F',route='%2Fusers'*/ SELECT 1"
+	if slave.last != want {
+		t.Errorf("slave query = %q, want %q", slave.last, want)
+	}
+
+	_, _ = balanced.ExecContext(ctx, "DELETE FROM t")
+	if !strings.HasPrefix(master.last, "/*action=") || !strings.HasSuffix(master.last, "*/ DELETE FROM t") {
+		t.Errorf("master query = %q, want the tags prepended", master.last)
+	}
+
+	_, _ = balanced.ExecContext(context.Background(), "DELETE FROM t")
+	if master.last != "DELETE FROM t" {
+		t.Errorf("master query = %q, want it untouched without tags", master.last)
+	}
+}
+
+func TestStickToMasterFor(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	master, slave := &fakeDB{}, &fakeDB{}
+	balanced := db.NewDB(master, []db.Database{slave}, db.WithClock(clock))
+	ctx := db.StickToMasterFor(context.Background(), time.Second)
+
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	if slave.reads != 1 {
+		t.Fatalf("slave reads = %d, want the read before any write on the slave", slave.reads)
+	}
+
+	_, _ = balanced.ExecContext(ctx, "UPDATE users SET age = 31")
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	_, _ = balanced.QueryContext(context.Background(), "SELECT 1")
+	if master.reads != 1 || slave.reads != 2 {
+		t.Fatalf("master/slave reads = %d/%d, want only the sticky read on the master", master.reads, slave.reads)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	_, _ = balanced.QueryContext(ctx, "SELECT 1")
+	if slave.reads != 3 {
+		t.Errorf("slave reads = %d, want the reads after the window back on the slave", slave.reads)
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"regexp"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrNotSQLXCompatible = errors.New("db is not sqlx.DB compatible")
+	ErrEmptyBulkArgs     = errors.New("bulk exec needs at least one argument")
+)
+
+// valuesClause matches the statements sqlx can expand into a multi-value INSERT
+var valuesClause = regexp.MustCompile(`(?i)\bVALUES\s*\(`)
+
+// bulkExecer is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type bulkExecer interface {
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// BulkNamedExec runs a named query for every element of args on the master.
+// Statements with a VALUES clause are expanded by sqlx into a single multi-value statement,
+// other statements, like an UPDATE, are executed once per element inside a single transaction, when the driver
+// can not tell the affected rows of one of them RowsAffected of the result returns its error.
+// The master has to be sqlx compatible, see WrapSQLX.
+func (db *DB) BulkNamedExec(ctx context.Context, query string, args []any) (sql.Result, error) {
+	if len(args) == 0 {
+		return nil, ErrEmptyBulkArgs
+	}
+	query = tagQuery(ctx, query)
+	db.markWrite(ctx)
+
+	return onMaster(ctx, db, query, func(master Database) (sql.Result, error) {
+		m, ok := master.(bulkExecer)
+		if !ok {
+			return nil, ErrNotSQLXCompatible
+		}
+
+		return bulkNamedExec(ctx, m, query, args)
+	}, slog.Int("rows", len(args)))
+}
+
+func bulkNamedExec(ctx context.Context, master bulkExecer, query string, args []any) (sql.Result, error) {
+	if valuesClause.MatchString(query) {
+		return master.NamedExecContext(ctx, query, args)
+	}
+
+	tx, err := master.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res := bulkResult{}
+	for _, arg := range args {
+		r, err := tx.NamedExecContext(ctx, query, arg)
+		if err != nil {
+			return nil, errors.Join(err, tx.Rollback())
+		}
+
+		res.add(r)
+	}
+
+	return res, tx.Commit()
+}
+
+// bulkResult sums the affected rows of the statements executed by BulkNamedExec
+type bulkResult struct {
+	lastInsertID int64
+	rowsAffected int64
+	rowsErr      error // Set when the affected rows of one of the statements are unknown
+}
+
+// add records the result of a statement, the statement already ran in the transaction so an unknown count of
+// affected rows is only reported by RowsAffected and does not fail the bulk exec.
+func (r *bulkResult) add(res sql.Result) {
+	if n, err := res.RowsAffected(); err != nil {
+		r.rowsErr = err
+	} else {
+		r.rowsAffected += n
+	}
+
+	// not every driver supports LastInsertId, the error is only relevant when the caller asks for it
+	if id, err := res.LastInsertId(); err == nil {
+		r.lastInsertID = id
+	}
+}
+
+func (r bulkResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r bulkResult) RowsAffected() (int64, error) {
+	if r.rowsErr != nil {
+		return 0, r.rowsErr
+	}
+
+	return r.rowsAffected, nil
+}
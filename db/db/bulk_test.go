@@ -0,0 +1,111 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/dbtest"
+	"github.com/OZahed/db/db/sqlite"
+)
+
+type user struct {
+	Name string `db:"name"`
+	Age  int    `db:"age"`
+}
+
+func newSQLiteDB(t *testing.T) db.DatabaseX {
+	t.Helper()
+
+	dbc, err := db.NewDatabaseConnection(db.Config{MaxOpen: 1}, &sqlite.SQLiteConnectionStringProvider{Memory: true})
+	if err != nil {
+		t.Fatalf("NewDatabaseConnection() error = %v", err)
+	}
+
+	dbx, err := db.WrapSQLX(dbc, "sqlite3")
+	if err != nil {
+		t.Fatalf("WrapSQLX() error = %v", err)
+	}
+	t.Cleanup(func() { _ = dbx.Close() })
+
+	if _, err := dbx.Exec("CREATE TABLE users (name TEXT PRIMARY KEY, age INTEGER)"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	return dbx
+}
+
+func TestBulkNamedExec(t *testing.T) {
+	ctx := context.Background()
+	balanced := db.NewBalancedDB(0, nil, newSQLiteDB(t)).(*db.DB)
+
+	users := []any{user{Name: "a", Age: 1}, user{Name: "b", Age: 2}, user{Name: "c", Age: 3}}
+	res, err := balanced.BulkNamedExec(ctx, "INSERT INTO users (name, age) VALUES (:name, :age)", users)
+	if err != nil {
+		t.Fatalf("BulkNamedExec(insert) error = %v", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("BulkNamedExec(insert) RowsAffected = %d, want 3", n)
+	}
+
+	older := []any{user{Name: "a", Age: 10}, user{Name: "b", Age: 20}}
+	res, err = balanced.BulkNamedExec(ctx, "UPDATE users SET age = :age WHERE name = :name", older)
+	if err != nil {
+		t.Fatalf("BulkNamedExec(update) error = %v", err)
+	}
+
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("BulkNamedExec(update) RowsAffected = %d, want 2", n)
+	}
+
+	var total int
+	if err := balanced.QueryRow("SELECT SUM(age) FROM users").Scan(&total); err != nil {
+		t.Fatalf("QueryRow() error = %v", err)
+	}
+
+	if total != 33 {
+		t.Errorf("sum of ages = %d, want 33", total)
+	}
+}
+
+func TestBulkNamedExecErrors(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := db.NewBalancedDB(0, nil, newSQLiteDB(t)).(*db.DB).BulkNamedExec(ctx, "INSERT", nil)
+	if !errors.Is(err, db.ErrEmptyBulkArgs) {
+		t.Errorf("BulkNamedExec() error = %v, want %v", err, db.ErrEmptyBulkArgs)
+	}
+
+	_, err = db.NewBalancedDB(0, nil, &fakeDB{}).(*db.DB).BulkNamedExec(ctx, "INSERT", []any{user{}})
+	if !errors.Is(err, db.ErrNotSQLXCompatible) {
+		t.Errorf("BulkNamedExec() error = %v, want %v", err, db.ErrNotSQLXCompatible)
+	}
+}
+
+func TestBulkNamedExecUnknownRowsAffected(t *testing.T) {
+	balanced, mocks := dbtest.NewMockBalanced(t, 0)
+	errRows := errors.New("rows affected not supported")
+
+	mocks[0].ExpectBegin()
+	mocks[0].ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewErrorResult(errRows))
+	mocks[0].ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	mocks[0].ExpectCommit()
+
+	older := []any{user{Name: "a", Age: 10}, user{Name: "b", Age: 20}}
+	res, err := balanced.BulkNamedExec(context.Background(), "UPDATE users SET age = :age WHERE name = :name", older)
+	if err != nil {
+		t.Fatalf("BulkNamedExec() error = %v", err)
+	}
+
+	if _, err := res.RowsAffected(); !errors.Is(err, errRows) {
+		t.Errorf("RowsAffected() error = %v, want %v", err, errRows)
+	}
+
+	if err := mocks[0].ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
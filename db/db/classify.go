@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// mysqlRetryableErrors are the server errors of MySQL meaning the connection was closed by the server,
+// ER_SERVER_SHUTDOWN and ER_CONNECTION_KILLED
+var mysqlRetryableErrors = map[uint16]bool{1053: true, 1927: true}
+
+// postgresRetryableCodes are the SQLSTATE codes of postgres meaning the server is going away or can not be reached
+// yet, admin_shutdown, crash_shutdown and cannot_connect_now. The codes of the class 08, the connection
+// exceptions, are retryable too.
+var postgresRetryableCodes = map[string]bool{"57P01": true, "57P02": true, "57P03": true}
+
+// IsRetryableError reports whether err means the connection to the node is bad, so the query can be tried again,
+// possibly on another node, rather than the query itself being wrong. It handles driver.ErrBadConn,
+// sql.ErrConnDone, network timeouts and the shutdown errors of postgres and MySQL.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		code := string(pqErr.Code)
+		return postgresRetryableCodes[code] || strings.HasPrefix(code, "08")
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlRetryableErrors[mysqlErr.Number]
+	}
+
+	return false
+}
+
+// WithErrorClassifier replaces IsRetryableError as the classifier deciding which errors mean the node is bad
+// for the fallback features of the DB, like WithMasterFailover.
+func WithErrorClassifier(isRetryable func(error) bool) Option {
+	return func(db *DB) {
+		db.isRetryable = isRetryable
+	}
+}
+
+// retryable classifies err with the classifier of WithErrorClassifier or IsRetryableError
+func (db *DB) retryable(err error) bool {
+	if db.isRetryable != nil {
+		return db.isRetryable(err)
+	}
+
+	return IsRetryableError(err)
+}
@@ -0,0 +1,60 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil"},
+		{name: "bad conn", err: fmt.Errorf("exec: %w", driver.ErrBadConn), want: true},
+		{name: "conn done", err: sql.ErrConnDone, want: true},
+		{name: "net timeout", err: &net.OpError{Op: "read", Err: context.DeadlineExceeded}, want: true},
+		{name: "postgres admin shutdown", err: &pq.Error{Code: "57P01"}, want: true},
+		{name: "postgres connection failure", err: &pq.Error{Code: "08006"}, want: true},
+		{name: "postgres syntax error", err: &pq.Error{Code: "42601"}},
+		{name: "mysql server shutdown", err: &mysql.MySQLError{Number: 1053}, want: true},
+		{name: "mysql duplicate entry", err: &mysql.MySQLError{Number: 1062}},
+		{name: "no rows", err: sql.ErrNoRows},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := db.IsRetryableError(tt.err); got != tt.want {
+				t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorClassifierDrivesFailover(t *testing.T) {
+	errReadOnly := errors.New("cannot execute in a read-only transaction")
+	master, standby := &fakeDB{execErr: errReadOnly}, &fakeDB{}
+	balanced := db.NewDB(master, []db.Database{standby},
+		db.WithMasterFailover(func(db.Database) (db.Database, error) { return standby, nil }),
+		db.WithErrorClassifier(func(err error) bool { return errors.Is(err, errReadOnly) }),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, _ = balanced.Exec("DELETE FROM t")
+	}
+
+	if _, err := balanced.Exec("DELETE FROM t"); err != nil || standby.writes != 1 {
+		t.Errorf("Exec() error = %v, standby writes = %d, want the classifier to fail the master over", err, standby.writes)
+	}
+}
@@ -0,0 +1,14 @@
+package db
+
+import "time"
+
+// Clock tells the balanced DB the current time, the slow queries are measured with it.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
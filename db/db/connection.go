@@ -1,10 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/uptrace/opentelemetry-go-extra/otelsql"
 	"go.opentelemetry.io/otel/attribute"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
@@ -16,32 +20,139 @@ type SQLDriverInstance interface {
 	DBName() string
 }
 
+// validator is implemented by the SQLDriverInstance providers which can detect misconfiguration before connecting
+type validator interface {
+	Validate() error
+}
+
 type Config struct {
-	Prometheus  bool
-	Otel        bool
+	// Prometheus registers the connection pool stats as prometheus metrics with Registerer
+	Prometheus bool
+	Otel       bool
+	// DriverName is the name the driver is registered under in database/sql, like "instrumented-postgres",
+	// the Name of the SQLDriverInstance is used when it is empty and still picks the bindvars and otel attributes
+	DriverName string
+	// Registerer receives the metrics of Prometheus, prometheus.DefaultRegisterer is used when it is nil
+	Registerer prometheus.Registerer
+	// Sqlx has no effect on NewDatabaseConnection, use NewDatabaseConnectionX to get a sqlx handle
 	Sqlx        bool
 	MaxIdle     int
 	MaxOpen     int
 	MaxLifetime time.Duration
+	// MaxIdleTime closes the connections idle for longer, before a serverless database kills them on its side
+	MaxIdleTime time.Duration
+	// ConnectAttempts is the number of open and ping attempts, zero opens the connection without pinging it
+	ConnectAttempts int
+	// ConnectBackoff is the wait after the first failed attempt, it doubles after each following attempt
+	ConnectBackoff time.Duration
 }
 
+// NewDatabaseConnection opens a *sql.DB for the driver and applies the pool settings of cfg.
+// When cfg.Otel is set the connection is instrumented and its stats are reported as otel metrics.
+// When cfg.Prometheus is set the pool stats are registered as prometheus metrics labeled with the driver and
+// database name, opening the same database again moves the metrics to the new pool. A failing registration, like
+// another collector using the same metrics, closes the connection.
+// When cfg.DriverName is set the connection is opened with it, and sqlx binds it like the driver's dialect,
+// so WrapSQLX(dbc, cfg.DriverName) uses the right bindvars.
+// When cfg.ConnectAttempts is set the connection is pinged and reopened with exponential backoff until it
+// is reachable.
 func NewDatabaseConnection(cfg Config, driver SQLDriverInstance) (*sql.DB, error) {
+	if v, ok := driver.(validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	dbc, err := connect(cfg, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	bindDriverName(cfg.driverName(driver), driver.Name())
+
+	if cfg.Otel {
+		otelsql.ReportDBStatsMetrics(dbc, otelsql.WithAttributes(getAttribute(driver.Name())))
+	}
+
+	if cfg.Prometheus {
+		if err := registerPrometheus(cfg.Registerer, dbc, driver); err != nil {
+			return nil, errors.Join(err, dbc.Close())
+		}
+	}
+
+	return dbc, nil
+}
+
+// WithConnectRetry returns a copy of cfg which pings the database after opening it and retries up to attempts
+// times, doubling the backoff after each failed attempt.
+func (cfg Config) WithConnectRetry(attempts int, backoff time.Duration) Config {
+	cfg.ConnectAttempts = attempts
+	cfg.ConnectBackoff = backoff
+	return cfg
+}
+
+// driverName returns the name the connection is opened with
+func (cfg Config) driverName(driver SQLDriverInstance) string {
+	if cfg.DriverName != "" {
+		return cfg.DriverName
+	}
+
+	return driver.Name()
+}
+
+// bindDriverName makes sqlx bind a custom registered driver name like its dialect,
+// names sqlx already knows are left untouched
+func bindDriverName(name, dialect string) {
+	if name == dialect || sqlx.BindType(name) != sqlx.UNKNOWN {
+		return
+	}
+
+	sqlx.BindDriver(name, sqlx.BindType(dialect))
+}
+
+func connect(cfg Config, driver SQLDriverInstance) (*sql.DB, error) {
+	if cfg.ConnectAttempts <= 0 {
+		return openDB(cfg, driver)
+	}
+
+	backoff := cfg.ConnectBackoff
+	var err error
+	for attempt := 1; attempt <= cfg.ConnectAttempts; attempt++ {
+		var dbc *sql.DB
+		dbc, err = openDB(cfg, driver)
+		if err == nil {
+			err = dbc.PingContext(context.Background())
+			if err == nil {
+				return dbc, nil
+			}
+
+			_ = dbc.Close()
+		}
+
+		if attempt < cfg.ConnectAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return nil, fmt.Errorf("connecting to %s failed after %d attempts: %w", driver.DBName(), cfg.ConnectAttempts, err)
+}
+
+func openDB(cfg Config, driver SQLDriverInstance) (*sql.DB, error) {
 	var dbc *sql.DB
 	var err error
 
 	if cfg.Otel {
-		dbc, err = otelsql.Open(driver.Name(), driver.ConnectionString(),
+		dbc, err = otelsql.Open(cfg.driverName(driver), driver.ConnectionString(),
 			otelsql.WithAttributes(getAttribute(driver.Name())),
 			otelsql.WithDBName(driver.DBName()),
 		)
-		if err != nil {
-			return nil, err
-		}
+	} else {
+		dbc, err = sql.Open(cfg.driverName(driver), driver.ConnectionString())
 	}
 
-	if cfg.Sqlx {
-		sqlx.NewDb(dbc, driver.Name())
-		otelsql.ReportDBStatsMetrics(dbc, otelsql.WithAttributes(getAttribute(driver.Name())))
+	if err != nil {
+		return nil, err
 	}
 
 	if cfg.MaxIdle > 0 {
@@ -56,10 +167,24 @@ func NewDatabaseConnection(cfg Config, driver SQLDriverInstance) (*sql.DB, error
 		dbc.SetConnMaxLifetime(cfg.MaxLifetime)
 	}
 
-	// Add Prometheus metrics
+	if cfg.MaxIdleTime > 0 {
+		dbc.SetConnMaxIdleTime(cfg.MaxIdleTime)
+	}
+
 	return dbc, nil
 }
 
+// NewDatabaseConnectionX works like NewDatabaseConnection but returns the connection wrapped in a sqlx.DB
+// bound to the driver name, so the sqlx extensions can be used on it.
+func NewDatabaseConnectionX(cfg Config, driver SQLDriverInstance) (*sqlx.DB, error) {
+	dbc, err := NewDatabaseConnection(cfg, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return sqlx.NewDb(dbc, cfg.driverName(driver)), nil
+}
+
 func getAttribute(driverName string) attribute.KeyValue {
 	switch driverName {
 	case "mysql":
@@ -0,0 +1,138 @@
+package db_test
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/psql"
+	"github.com/OZahed/db/db/sqlite"
+)
+
+func TestNewDatabaseConnectionWithoutOtel(t *testing.T) {
+	driver := &psql.PostgreSQLConnectionStringProvider{
+		Host:         "localhost",
+		Port:         5432,
+		User:         "user",
+		Password:     "pass",
+		DatabaseName: "test",
+	}
+
+	dbc, err := db.NewDatabaseConnection(db.Config{Otel: false, MaxIdle: 5}, driver)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnection() error = %v", err)
+	}
+	defer dbc.Close()
+
+	if dbc == nil {
+		t.Fatal("NewDatabaseConnection() returned a nil connection")
+	}
+}
+
+func TestNewDatabaseConnectionUnknownDriver(t *testing.T) {
+	driver := &unknownDriver{}
+
+	if _, err := db.NewDatabaseConnection(db.Config{Otel: false, MaxIdle: 5}, driver); err == nil {
+		t.Fatal("NewDatabaseConnection() expected an error for an unregistered driver")
+	}
+}
+
+func TestNewDatabaseConnectionX(t *testing.T) {
+	driver := &psql.PostgreSQLConnectionStringProvider{Host: "localhost", Port: 5432, DatabaseName: "test"}
+
+	dbx, err := db.NewDatabaseConnectionX(db.Config{Sqlx: true, MaxOpen: 2}, driver)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnectionX() error = %v", err)
+	}
+	defer dbx.Close()
+
+	if got := dbx.DriverName(); got != driver.Name() {
+		t.Errorf("DriverName() = %q, want %q", got, driver.Name())
+	}
+}
+
+func TestNewDatabaseConnectionRetry(t *testing.T) {
+	driver := &psql.PostgreSQLConnectionStringProvider{Host: "127.0.0.1", Port: 1, DatabaseName: "test"}
+	cfg := db.Config{}.WithConnectRetry(2, time.Millisecond)
+
+	_, err := db.NewDatabaseConnection(cfg, driver)
+	if err == nil {
+		t.Fatal("NewDatabaseConnection() expected an error for an unreachable database")
+	}
+
+	if !strings.Contains(err.Error(), "2 attempts") {
+		t.Errorf("NewDatabaseConnection() error = %v, want the attempt count", err)
+	}
+}
+
+func TestNewDatabaseConnectionXDriverName(t *testing.T) {
+	sql.Register("instrumented-postgres", &pq.Driver{})
+	driver := &psql.PostgreSQLConnectionStringProvider{Host: "localhost", Port: 5432, DatabaseName: "test"}
+
+	dbx, err := db.NewDatabaseConnectionX(db.Config{DriverName: "instrumented-postgres"}, driver)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnectionX() error = %v", err)
+	}
+	defer dbx.Close()
+
+	if got := dbx.DriverName(); got != "instrumented-postgres" {
+		t.Errorf("DriverName() = %q, want the registered name", got)
+	}
+
+	if got := dbx.Rebind("SELECT ?"); got != "SELECT $1" {
+		t.Errorf("Rebind() = %q, want the postgres bindvars", got)
+	}
+}
+
+type unknownDriver struct{}
+
+func (unknownDriver) Name() string             { return "unknown-driver" }
+func (unknownDriver) ConnectionString() string { return "" }
+func (unknownDriver) DBName() string           { return "" }
+
+func TestNewDatabaseConnectionPrometheus(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	cfg := db.Config{Prometheus: true, Registerer: reg}
+	driver := &sqlite.SQLiteConnectionStringProvider{Memory: true}
+
+	dbc, err := db.NewDatabaseConnection(cfg, driver)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnection() error = %v", err)
+	}
+	defer dbc.Close()
+
+	if err := dbc.Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	want := `
+# HELP db_pool_open_connections Number of established connections, both in use and idle.
+# TYPE db_pool_open_connections gauge
+db_pool_open_connections{db_name=":memory:",driver="sqlite3"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "db_pool_open_connections"); err != nil {
+		t.Error(err)
+	}
+
+	// reopening the database after closing it reports the stats of the new pool
+	_ = dbc.Close()
+	reopened, err := db.NewDatabaseConnection(cfg, driver)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnection() of the same database error = %v", err)
+	}
+	defer reopened.Close()
+
+	if err := reopened.Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "db_pool_open_connections"); err != nil {
+		t.Error(err)
+	}
+}
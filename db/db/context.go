@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// loggerKey is the context key of the logger added by ContextWithLogger
+type loggerKey struct{}
+
+// affinityKeyKey is the context key of the key added by WithAffinityKey
+type affinityKeyKey struct{}
+
+// queryTagKey is the context key of the tags added by WithQueryTag
+type queryTagKey struct{}
+
+// stickyKey is the context key of the stickiness added by StickToMasterFor
+type stickyKey struct{}
+
+// stickiness is the read-your-writes window of a context, lastWrite is the UnixNano time of its last write
+type stickiness struct {
+	window    time.Duration
+	lastWrite int64
+}
+
+// ContextWithLogger returns a copy of ctx carrying lg. The slow queries made with the context methods of DB,
+// like QueryContext or ExecContext, are logged to lg instead of the logger of the DB, so a logger holding
+// the attributes of the request, like its trace id, correlates the DB logs with the request.
+func ContextWithLogger(ctx context.Context, lg *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, lg)
+}
+
+// LoggerFromContext returns the logger added to ctx by ContextWithLogger or nil.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	lg, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	return lg
+}
+
+// WithAffinityKey returns a copy of ctx carrying key. The reads made with the context, like QueryContext,
+// go to the replica the key hashes to instead of the next one of the round-robin, so the reads of a key,
+// like a user id, keep the buffer cache of a single replica warm. When that replica is unhealthy the read
+// falls back to the round-robin.
+func WithAffinityKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, affinityKeyKey{}, key)
+}
+
+func affinityKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(affinityKeyKey{}).(string)
+	return key, ok
+}
+
+// affinityReplica returns the replica key hashes to, replicas must not be empty
+func affinityReplica(key string, replicas []int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return replicas[h.Sum32()%uint32(len(replicas))]
+}
+
+// WithQueryTag returns a copy of ctx carrying tags. QueryContext, QueryRowContext and ExecContext prepend them to
+// the query as a sqlcommenter comment, like /*controller='users',route='%2Fusers'*/, so the slow query log of the
+// database attributes the queries. The keys are sorted and the keys and values are URL encoded, which keeps quotes
+// and the end of the comment out of them. The tags are added to the ones already in ctx.
+func WithQueryTag(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for k, v := range queryTags(ctx) {
+		merged[k] = v
+	}
+
+	for k, v := range tags {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, queryTagKey{}, merged)
+}
+
+func queryTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(queryTagKey{}).(map[string]string)
+	return tags
+}
+
+// tagQuery prepends the tags of ctx to query, it returns query unchanged when there are none
+func tagQuery(ctx context.Context, query string) string {
+	tags := queryTags(ctx)
+	if len(tags) == 0 {
+		return query
+	}
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, commentEscape(k)+"='"+commentEscape(v)+"'")
+	}
+	sort.Strings(pairs)
+
+	return "/*" + strings.Join(pairs, ",") + "*/ " + query
+}
+
+// commentEscape URL encodes s with the spaces as %20, like sqlcommenter
+func commentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// StickToMasterFor returns a copy of ctx whose reads go to the master for d after each write made with it, so a
+// request reads its own writes without waiting for the replicas to catch up. The writes are the context methods
+// of DB sending to the master, like ExecContext, BeginTx or InTx. The reads without a write in the last d and
+// the reads of other contexts are routed as usual.
+func StickToMasterFor(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, stickyKey{}, &stickiness{window: d})
+}
+
+// markWrite starts the read-your-writes window of ctx
+func (db *DB) markWrite(ctx context.Context) {
+	if s, ok := ctx.Value(stickyKey{}).(*stickiness); ok {
+		atomic.StoreInt64(&s.lastWrite, db.clock.Now().UnixNano())
+	}
+}
+
+// stickToMaster reports whether ctx wrote in its read-your-writes window
+func (db *DB) stickToMaster(ctx context.Context) bool {
+	s, ok := ctx.Value(stickyKey{}).(*stickiness)
+	if !ok {
+		return false
+	}
+
+	lastWrite := atomic.LoadInt64(&s.lastWrite)
+	return lastWrite != 0 && db.clock.Now().UnixNano()-lastWrite < int64(s.window)
+}
@@ -0,0 +1,41 @@
+package crdb
+
+import (
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/psql"
+)
+
+const defaultPort = 26257
+
+// CockroachDBConnStringProvider builds the DSN of a CockroachDB cluster, which speaks the postgres wire protocol,
+// so the connection is opened with the postgres driver. The SSL settings are the ones of the postgres provider.
+type CockroachDBConnStringProvider struct {
+	psql.PostgreSQLConnectionStringProvider
+	// Options is the options parameter sent to the cluster, like --cluster=foo for the routing of a shared cluster
+	Options string
+}
+
+// ConnectionString returns the postgres DSN with the CockroachDB port, 26257, unless Port is set
+func (s *CockroachDBConnStringProvider) ConnectionString() string {
+	p := s.PostgreSQLConnectionStringProvider
+	if p.Port == 0 {
+		p.Port = defaultPort
+	}
+
+	if s.Options != "" {
+		// a copy of the params, the Options of the provider must not leak into the embedded provider
+		p.Params = make(map[string]string, len(s.Params)+1)
+		for k, v := range s.Params {
+			p.Params[k] = v
+		}
+
+		p.Params["options"] = s.Options
+	}
+
+	return p.ConnectionString()
+}
+
+// SafeString returns the connection string with the password masked, use it when logging.
+func (s *CockroachDBConnStringProvider) SafeString() string {
+	return db.MaskDSN(s.ConnectionString())
+}
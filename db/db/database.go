@@ -20,21 +20,42 @@ type Database interface {
 // Queryer is a subset of the sql.DB interface but only for methods which works with actual data.
 // It is used to wrap the sql.DB and sql.Tx types with extra functionality.
 type Queryer interface {
-	Begin() (*sql.Tx, error)
-	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	ReadQuerier
+	WriteQuerier
+
+	// Prepare and PrepareContext are not included in the Queryer interface
+	// because sql.DB and sqlx.DB return a *sql.Stmt while the balanced DB prepares a Stmt on every
+	// physical instance of the database, see DB.Prepare.
+}
+
+// ReadQuerier is the subset of Queryer which only reads data.
+type ReadQuerier interface {
 	Query(query string, args ...interface{}) (*sql.Rows, error)
 	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
-	// TODO: Implement Stmt interface compatible Prepare and PrepareContext methods.
-	// Prepare and PrepareContext are not included in the Queryer interface
-	// because they return a Stmt interface which is not a Queryer compatible with sqlx and sql interface
-	// These methods should run on all physical instance of the database.
-	// Prepare(query string) (Stmt, error)
-	// PrepareContext(ctx context.Context, query string) (Stmt, error)
+// WriteQuerier is the subset of Queryer which manipulates data or starts transactions.
+type WriteQuerier interface {
+	Begin() (*sql.Tx, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ReadQuerierX is a ReadQuerier which can scan the results into structs.
+type ReadQuerierX interface {
+	ReadQuerier
+	Get(dest interface{}, query string, args ...interface{}) error
+	Select(dest interface{}, query string, args ...interface{}) error
+}
+
+// WriteQuerierX is a WriteQuerier which can bind the fields of a struct or a map to named parameters.
+type WriteQuerierX interface {
+	WriteQuerier
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
 }
 
 type DatabaseX interface {
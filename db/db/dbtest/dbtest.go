@@ -0,0 +1,36 @@
+// Package dbtest provides helpers to test the code using a balanced db.DB.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/OZahed/db/db"
+)
+
+// NewMockBalanced returns a balanced DB over a sqlmock master and nSlaves sqlmock slaves, every node is sqlx
+// compatible. The mocks are returned in routing order, the master first and then the slaves, so mocks[i] is the
+// node i of db.DB.RoutingStats. The nodes are closed when the test finishes.
+func NewMockBalanced(t testing.TB, nSlaves int) (*db.DB, []sqlmock.Sqlmock) {
+	t.Helper()
+
+	nodes := make([]db.Database, nSlaves+1)
+	mocks := make([]sqlmock.Sqlmock, nSlaves+1)
+	for i := range nodes {
+		raw, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("creating sqlmock %d: %v", i, err)
+		}
+		t.Cleanup(func() { _ = raw.Close() })
+
+		node, err := db.WrapSQLX(raw, "sqlmock")
+		if err != nil {
+			t.Fatalf("wrapping sqlmock %d: %v", i, err)
+		}
+
+		nodes[i], mocks[i] = node, mock
+	}
+
+	return db.NewDB(nodes[0], nodes[1:]), mocks
+}
@@ -0,0 +1,157 @@
+package dbtest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/dbtest"
+)
+
+func TestNewMockBalanced(t *testing.T) {
+	balanced, mocks := dbtest.NewMockBalanced(t, 2)
+	if len(mocks) != 3 {
+		t.Fatalf("got %d mocks, want the master and 2 slaves", len(mocks))
+	}
+
+	mocks[0].ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, mock := range mocks[1:] {
+		mock.ExpectQuery("SELECT name FROM users").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+	}
+
+	if _, err := balanced.Exec("DELETE FROM users"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		var name string
+		if err := balanced.Get(&name, "SELECT name FROM users"); err != nil || name != "alice" {
+			t.Fatalf("Get() = %q, %v, want alice", name, err)
+		}
+	}
+
+	for i, mock := range mocks {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("mock %d: %v", i, err)
+		}
+	}
+}
+
+func TestFakeDatabase(t *testing.T) {
+	master, slaves := &dbtest.FakeDatabase{}, []*dbtest.FakeDatabase{{}, {}}
+	slaves[1].Scan = func(dest any, _ string, _ ...any) error {
+		*dest.(*string) = "alice"
+		return nil
+	}
+	balanced := db.NewDB(master, []db.Database{slaves[0], slaves[1]})
+
+	_, _ = balanced.Exec("DELETE FROM users WHERE id = ?", 42)
+	_, _ = balanced.Query("SELECT 1")
+
+	var name string
+	if err := balanced.Get(&name, "SELECT name FROM users"); err != nil || name != "alice" {
+		t.Fatalf("Get() = %q, %v, want alice", name, err)
+	}
+
+	want := []dbtest.Call{{Method: "Exec", Query: "DELETE FROM users WHERE id = ?", Args: []any{42}}}
+	if got := master.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("master calls = %v, want %v", got, want)
+	}
+
+	if got := slaves[1].Queries(); !reflect.DeepEqual(got, []string{"SELECT 1", "SELECT name FROM users"}) {
+		t.Errorf("slave 2 queries = %q, want both reads", got)
+	}
+
+	if got := slaves[0].Calls(); len(got) != 0 {
+		t.Errorf("slave 1 calls = %v, want none", got)
+	}
+}
+
+func TestFakeDatabaseRows(t *testing.T) {
+	users := func(_ string, args ...any) (*dbtest.Rows, error) {
+		rows := dbtest.NewRows("name", "age").AddRow("alice", 30)
+		if len(args) == 0 {
+			rows.AddRow("bob", 25)
+		}
+
+		return rows, nil
+	}
+	balanced := db.NewDB(&dbtest.FakeDatabase{}, []db.Database{&dbtest.FakeDatabase{Rows: users}})
+
+	rows, err := balanced.QueryContext(context.Background(), "SELECT name, age FROM users")
+	if err != nil {
+		t.Fatalf("QueryContext() error = %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		var age int
+		if err := rows.Scan(&name, &age); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil || !reflect.DeepEqual(names, []string{"alice", "bob"}) {
+		t.Errorf("QueryContext() rows = %q, %v, want alice and bob", names, err)
+	}
+
+	var age int
+	if err := balanced.QueryRow("SELECT name, age FROM users WHERE id = ?", 1).Scan(new(string), &age); err != nil {
+		t.Errorf("QueryRow().Scan() error = %v", err)
+	} else if age != 30 {
+		t.Errorf("QueryRow().Scan() age = %d, want 30", age)
+	}
+
+	type user struct {
+		Name string `db:"name"`
+		Age  int    `db:"age"`
+	}
+	var got []user
+	if err := balanced.Select(&got, "SELECT name, age FROM users"); err != nil || len(got) != 2 || got[1].Age != 25 {
+		t.Errorf("Select() = %+v, %v, want alice and bob", got, err)
+	}
+
+	empty := db.NewDB(&dbtest.FakeDatabase{}, []db.Database{&dbtest.FakeDatabase{}})
+	if _, err := empty.Query("SELECT 1"); !errors.Is(err, dbtest.ErrNoResult) {
+		t.Errorf("Query() without rows error = %v, want %v", err, dbtest.ErrNoResult)
+	}
+
+	failing := db.NewDB(&dbtest.FakeDatabase{}, []db.Database{&dbtest.FakeDatabase{Err: sql.ErrConnDone}})
+	if err := failing.QueryRow("SELECT 1").Scan(new(int)); !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("QueryRow().Scan() error = %v, want %v", err, sql.ErrConnDone)
+	}
+}
+
+func TestFakeDatabaseInTx(t *testing.T) {
+	master := &dbtest.FakeDatabase{}
+	balanced := db.NewDB(master, nil)
+
+	err := balanced.InTx(context.Background(), nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE users SET age = ? WHERE id = ?", 31, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+
+	want := []dbtest.Call{
+		{Method: "BeginTx"},
+		{Method: "Tx.ExecContext", Query: "UPDATE users SET age = ? WHERE id = ?", Args: []any{31, 1}},
+	}
+	if got := master.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("master calls = %v, want %v", got, want)
+	}
+
+	failing := db.NewDB(&dbtest.FakeDatabase{Err: sql.ErrConnDone}, nil)
+	err = failing.InTx(context.Background(), nil, func(*sql.Tx) error { return nil })
+	if !errors.Is(err, sql.ErrConnDone) {
+		t.Errorf("InTx() error = %v, want %v", err, sql.ErrConnDone)
+	}
+}
@@ -0,0 +1,128 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+var errNotSupported = errors.New("fake database driver does not prepare statements")
+
+// Rows are the rows a FakeDatabase returns for a read, like the rows of sqlmock.
+type Rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// NewRows returns empty rows with the given columns.
+func NewRows(columns ...string) *Rows {
+	return &Rows{columns: columns}
+}
+
+// AddRow appends a row with a value for every column, the values are scanned like the ones of a real driver.
+func (r *Rows) AddRow(values ...driver.Value) *Rows {
+	r.values = append(r.values, values)
+	return r
+}
+
+// resultKey is the context key of the result of a read, the FakeDatabase decides it before calling the driver
+type resultKey struct{}
+
+type result struct {
+	rows *Rows
+	err  error
+}
+
+// connector opens the connections of the driver backing the reads and the transactions of a FakeDatabase
+type connector struct {
+	f *FakeDatabase
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn(c), nil }
+func (c connector) Driver() driver.Driver                        { return c }
+func (c connector) Open(string) (driver.Conn, error)             { return conn(c), nil }
+
+// conn answers the queries of the FakeDatabase with the result carried by their context, the execs and queries
+// made inside a transaction carry none and are answered and recorded by the FakeDatabase itself
+type conn struct {
+	f *FakeDatabase
+}
+
+func (conn) Prepare(string) (driver.Stmt, error) { return nil, errNotSupported }
+func (conn) Close() error                        { return nil }
+func (conn) Begin() (driver.Tx, error)           { return tx{}, nil }
+
+func (conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) { return tx{}, nil }
+
+// CheckNamedValue accepts any argument, the arguments are only recorded by the FakeDatabase
+func (conn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.record("Tx.ExecContext", query, values(args))
+	res, err := c.f.result()
+	if err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		return driver.RowsAffected(0), nil
+	}
+
+	return res, nil
+}
+
+func (c conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, ok := ctx.Value(resultKey{}).(result)
+	if !ok {
+		c.f.record("Tx.QueryContext", query, values(args))
+		ctx = c.f.withRows(ctx, query, values(args))
+		res = ctx.Value(resultKey{}).(result)
+	}
+
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	if res.rows == nil {
+		return nil, ErrNoResult
+	}
+
+	return &rows{Rows: res.rows}, nil
+}
+
+// values returns the values of args in order
+func values(args []driver.NamedValue) []any {
+	vs := make([]any, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+
+	return vs
+}
+
+// tx is a transaction with nothing to commit or roll back, the FakeDatabase has no state
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+// rows iterates over Rows, every query gets its own
+type rows struct {
+	*Rows
+	next int
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/OZahed/db/db"
+)
+
+var _ db.DatabaseX = (*FakeDatabase)(nil)
+
+// ErrNoResult is returned by the reads of a FakeDatabase which has neither Err nor a result for them.
+var ErrNoResult = errors.New("fake database has no result for the query")
+
+// Call is a call recorded by a FakeDatabase, Query is empty for the calls without one like Ping and Begin.
+type Call struct {
+	Method string
+	Query  string
+	Args   []any
+}
+
+// FakeDatabase is an in-memory db.DatabaseX recording every call, it lets the tests assert which node of a
+// balanced DB served a query without the expectations of sqlmock. Query and QueryRow return the rows of Rows,
+// Get and Select are answered by Scan or else scan the rows of Rows like sqlx. The reads fail with ErrNoResult
+// when neither is set. Begin and BeginTx return real transactions, their execs and queries are answered like
+// the ones of the FakeDatabase and recorded with a "Tx." prefix, like "Tx.ExecContext".
+// It is safe for concurrent use, the exported fields must be set before the first call.
+type FakeDatabase struct {
+	// Err is returned by every query, exec and transaction
+	Err error
+	// PingErr is returned by Ping and PingContext
+	PingErr error
+	// Result is returned by the successful execs
+	Result sql.Result
+	// Rows returns the rows of the reads, see NewRows
+	Rows func(query string, args ...any) (*Rows, error)
+	// Scan answers Get and Select, they scan the rows of Rows without it
+	Scan func(dest any, query string, args ...any) error
+
+	mu    sync.Mutex
+	calls []Call
+	once  sync.Once
+	xdb   *sqlx.DB
+}
+
+// Calls returns the calls made so far in order.
+func (f *FakeDatabase) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// Queries returns the queries made so far in order, the calls without a query are left out.
+func (f *FakeDatabase) Queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var queries []string
+	for _, c := range f.calls {
+		if c.Query != "" {
+			queries = append(queries, c.Query)
+		}
+	}
+
+	return queries
+}
+
+// Reset forgets the calls made so far.
+func (f *FakeDatabase) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = nil
+}
+
+func (f *FakeDatabase) record(method, query string, args []any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{Method: method, Query: query, Args: args})
+}
+
+func (f *FakeDatabase) result() (sql.Result, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.Result, nil
+}
+
+func (f *FakeDatabase) scan(dest any, query string, args []any) error {
+	if f.Scan == nil || f.Err != nil {
+		return f.Err
+	}
+
+	return f.Scan(dest, query, args...)
+}
+
+// conn returns the sqlx database over the driver answering the reads, the result of a read is passed to the driver
+// through the context, see withRows.
+func (f *FakeDatabase) conn() *sqlx.DB {
+	f.once.Do(func() {
+		f.xdb = sqlx.NewDb(sql.OpenDB(connector{f: f}), "dbtest")
+	})
+
+	return f.xdb
+}
+
+// withRows returns ctx carrying the result of query for the driver
+func (f *FakeDatabase) withRows(ctx context.Context, query string, args []any) context.Context {
+	res := result{err: f.Err}
+	switch {
+	case res.err != nil:
+	case f.Rows == nil:
+		res.err = ErrNoResult
+	default:
+		res.rows, res.err = f.Rows(query, args...)
+	}
+
+	return context.WithValue(ctx, resultKey{}, res)
+}
+
+func (f *FakeDatabase) Close() error {
+	f.record("Close", "", nil)
+	return f.conn().Close()
+}
+
+func (f *FakeDatabase) Ping() error {
+	f.record("Ping", "", nil)
+	return f.PingErr
+}
+
+func (f *FakeDatabase) PingContext(_ context.Context) error {
+	f.record("PingContext", "", nil)
+	return f.PingErr
+}
+
+func (f *FakeDatabase) Begin() (*sql.Tx, error) {
+	f.record("Begin", "", nil)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.conn().Begin()
+}
+
+func (f *FakeDatabase) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	f.record("BeginTx", "", nil)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return f.conn().BeginTx(ctx, opts)
+}
+
+func (f *FakeDatabase) Exec(query string, args ...interface{}) (sql.Result, error) {
+	f.record("Exec", query, args)
+	return f.result()
+}
+
+func (f *FakeDatabase) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	f.record("ExecContext", query, args)
+	return f.result()
+}
+
+func (f *FakeDatabase) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	f.record("NamedExec", query, []any{arg})
+	return f.result()
+}
+
+func (f *FakeDatabase) NamedExecContext(_ context.Context, query string, arg interface{}) (sql.Result, error) {
+	f.record("NamedExecContext", query, []any{arg})
+	return f.result()
+}
+
+func (f *FakeDatabase) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	f.record("Query", query, args)
+	return f.conn().QueryContext(f.withRows(context.Background(), query, args), query, args...)
+}
+
+func (f *FakeDatabase) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	f.record("QueryContext", query, args)
+	return f.conn().QueryContext(f.withRows(ctx, query, args), query, args...)
+}
+
+func (f *FakeDatabase) QueryRow(query string, args ...interface{}) *sql.Row {
+	f.record("QueryRow", query, args)
+	return f.conn().QueryRowContext(f.withRows(context.Background(), query, args), query, args...)
+}
+
+func (f *FakeDatabase) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	f.record("QueryRowContext", query, args)
+	return f.conn().QueryRowContext(f.withRows(ctx, query, args), query, args...)
+}
+
+func (f *FakeDatabase) Get(dest interface{}, query string, args ...interface{}) error {
+	f.record("Get", query, args)
+	if f.Scan == nil {
+		return f.conn().GetContext(f.withRows(context.Background(), query, args), dest, query, args...)
+	}
+
+	return f.scan(dest, query, args)
+}
+
+func (f *FakeDatabase) Select(dest interface{}, query string, args ...interface{}) error {
+	f.record("Select", query, args)
+	if f.Scan == nil {
+		return f.conn().SelectContext(f.withRows(context.Background(), query, args), dest, query, args...)
+	}
+
+	return f.scan(dest, query, args)
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownReplica is returned by DrainReplica for a database which is not one of the routed slaves
+var ErrUnknownReplica = errors.New("not a replica of the balanced db")
+
+// errDraining is the error of the drained replicas
+var errDraining = errors.New("drained")
+
+// drainPollInterval is how often DrainReplica checks the reads still running on the drained replica
+const drainPollInterval = 10 * time.Millisecond
+
+// DrainReplica stops routing reads to the slave replica and waits for the reads already running on it to return,
+// for a rolling restart of the replicas. The replica is marked unhealthy and removed from the reads at once, when
+// ctx is done first its error is returned and the replica stays removed. The rows of Query and QueryContext are
+// read after they return so they are not waited for. The replica keeps its index in pdbs, it is not closed and is
+// still covered by Close, Ping and Prepare. It returns ErrUnknownReplica when replica is not a routed slave.
+func (db *DB) DrainReplica(ctx context.Context, replica Database) error {
+	node, err := db.removeReplica(replica)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for atomic.LoadInt64(&db.inflight[node]) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return fmt.Errorf("draining node %d: %w", node, ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// removeReplica takes replica out of the routing of the reads and returns its index in pdbs. The reads pick their
+// node under mu, once it is released no new read can be counted on the replica.
+func (db *DB) removeReplica(replica Database) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	i := slices.IndexFunc(db.replicas, func(idx int) bool { return db.pdbs[idx] == replica })
+	if i < 0 {
+		return 0, ErrUnknownReplica
+	}
+	node := db.replicas[i]
+
+	// nodeErrs is guarded by mu which is already held, so the node is not marked with SetHealthy
+	db.nodeErrs[node] = errDraining
+	atomic.StoreUint32(&db.down[node], 1)
+
+	// the slices are copied as the reads keep using the ones they picked
+	remove := func(nodes []int) []int {
+		return slices.DeleteFunc(slices.Clone(nodes), func(idx int) bool { return idx == node })
+	}
+	db.replicas = remove(db.replicas)
+	db.localReplicas = remove(db.localReplicas)
+	if i = slices.Index(db.xnodes, node); i >= 0 {
+		db.xpdbs = slices.Delete(slices.Clone(db.xpdbs), i, i+1)
+		db.xnodes = remove(db.xnodes)
+		db.localXNodes = remove(db.localXNodes)
+	}
+
+	return node, nil
+}
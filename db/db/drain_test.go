@@ -0,0 +1,120 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+)
+
+// blockingReader is a fakeDB whose reads block until release is closed, started gets a value once a read runs
+type blockingReader struct {
+	fakeDB
+	started chan struct{}
+	release chan struct{}
+	n       int64
+}
+
+func newBlockingReader() *blockingReader {
+	return &blockingReader{started: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (b *blockingReader) QueryContext(_ context.Context, _ string, _ ...interface{}) (*sql.Rows, error) {
+	atomic.AddInt64(&b.n, 1)
+	b.started <- struct{}{}
+	<-b.release
+	return nil, nil
+}
+
+func (b *blockingReader) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return b.QueryContext(context.Background(), query, args...)
+}
+
+// startRead runs a read on the first slave of balanced, which has to be a blockingReader, and waits for it to run
+func startRead(t *testing.T, balanced *db.DB, slave *blockingReader) <-chan error {
+	t.Helper()
+
+	// the other slave is skipped while the read is routed
+	balanced.SetHealthy(2, false)
+	defer balanced.SetHealthy(2, true)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := balanced.QueryContext(context.Background(), "SELECT 1")
+		done <- err
+	}()
+
+	select {
+	case <-slave.started:
+	case <-time.After(time.Second):
+		t.Fatal("the read did not reach the drained slave")
+	}
+
+	return done
+}
+
+func TestDrainReplica(t *testing.T) {
+	drained, other := newBlockingReader(), &readCounter{}
+	balanced := db.NewDB(&fakeDB{}, []db.Database{drained, other})
+	read := startRead(t, balanced, drained)
+
+	drainDone := make(chan error, 1)
+	go func() { drainDone <- balanced.DrainReplica(context.Background(), drained) }()
+
+	select {
+	case err := <-drainDone:
+		t.Fatalf("DrainReplica() = %v before the read in flight returned", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := balanced.Query("SELECT 1"); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+	}
+	if n := atomic.LoadInt64(&drained.n); n != 1 {
+		t.Errorf("drained slave got %d reads, want only the one in flight", n)
+	}
+	if n := atomic.LoadInt64(&other.n); n != 4 {
+		t.Errorf("other slave got %d reads, want 4", n)
+	}
+
+	close(drained.release)
+	if err := <-read; err != nil {
+		t.Errorf("QueryContext() in flight error = %v", err)
+	}
+
+	select {
+	case err := <-drainDone:
+		if err != nil {
+			t.Errorf("DrainReplica() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DrainReplica() did not return once the read in flight returned")
+	}
+
+	if err := balanced.DrainReplica(context.Background(), drained); !errors.Is(err, db.ErrUnknownReplica) {
+		t.Errorf("DrainReplica() of a drained slave error = %v, want %v", err, db.ErrUnknownReplica)
+	}
+}
+
+func TestDrainReplicaContextDone(t *testing.T) {
+	drained := newBlockingReader()
+	balanced := db.NewDB(&fakeDB{}, []db.Database{drained, &readCounter{}})
+	read := startRead(t, balanced, drained)
+	defer func() {
+		close(drained.release)
+		<-read
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := balanced.DrainReplica(ctx, drained); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DrainReplica() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
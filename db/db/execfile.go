@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ExecFile runs the statements of the file at path in fsys on the master, in order and in a single transaction.
+// The statements are split on every ';', the splitter does not know about string literals, comments or
+// procedure bodies so the file must not contain a ';' other than the statement terminators.
+// On the first failing statement the transaction is rolled back and its index, starting at 0, is reported.
+func (db *DB) ExecFile(ctx context.Context, fsys fs.FS, path string) error {
+	content, err := fs.ReadFile(fsys, path)
+	if err != nil {
+		return err
+	}
+
+	stmts := splitStatements(string(content))
+
+	return db.InTx(ctx, nil, func(tx *sql.Tx) error {
+		for i, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("%s: statement %d: %w", path, i, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// splitStatements splits script on ';' and drops the empty statements
+func splitStatements(script string) []string {
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	return stmts
+}
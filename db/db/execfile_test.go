@@ -0,0 +1,38 @@
+package db_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestExecFile(t *testing.T) {
+	ctx := context.Background()
+	balanced := db.NewDB(newSQLiteDB(t), nil)
+	fsys := fstest.MapFS{
+		"seed.sql": {Data: []byte(`
+			INSERT INTO users (name, age) VALUES ('alice', 30);
+			INSERT INTO users (name, age) VALUES ('bob', 40);
+		`)},
+		"broken.sql": {Data: []byte(`
+			INSERT INTO users (name, age) VALUES ('carol', 50);
+			INSERT INTO missing (name) VALUES ('dave');
+		`)},
+	}
+
+	if err := balanced.ExecFile(ctx, fsys, "seed.sql"); err != nil {
+		t.Fatalf("ExecFile() error = %v", err)
+	}
+
+	err := balanced.ExecFile(ctx, fsys, "broken.sql")
+	if err == nil || !strings.Contains(err.Error(), "statement 1") {
+		t.Fatalf("ExecFile() error = %v, want the index of the failing statement", err)
+	}
+
+	if got := countUsers(t, balanced); got != 2 {
+		t.Errorf("users = %d, want the broken file rolled back", got)
+	}
+}
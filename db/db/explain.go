@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	RoleMaster = "master"
+	RoleSlave  = "slave"
+)
+
+// ExplainContext runs EXPLAIN for query on the node a read would be routed to and returns the plan,
+// the columns of each row are separated by a tab and the rows by a new line. The query itself is not executed.
+func (db *DB) ExplainContext(ctx context.Context, query string, args ...interface{}) (string, error) {
+	explain := "EXPLAIN " + query
+	node, pdb, err := db.slave(ctx)
+	defer db.doneReading(node)
+	if err != nil {
+		return "", err
+	}
+	db.explain(explain, nodeRole(node))
+
+	rows, err := pdb.QueryContext(ctx, explain, args...)
+	if err != nil {
+		return "", fmt.Errorf("explaining query: %w", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("explaining query: %w", err)
+	}
+
+	var plan strings.Builder
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", fmt.Errorf("explaining query: %w", err)
+		}
+
+		for i, v := range values {
+			if i > 0 {
+				plan.WriteByte('\t')
+			}
+			plan.WriteString(v.String)
+		}
+		plan.WriteByte('\n')
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("explaining query: %w", err)
+	}
+
+	return plan.String(), nil
+}
+
+// explain calls the explain hook, if any, before query is dispatched to a node of role
+func (db *DB) explain(query, role string) {
+	if db.explainHook != nil {
+		db.explainHook(query, role)
+	}
+}
+
+// nodeRole returns the role of the node at index node in pdbs
+func nodeRole(node int) string {
+	if node == masterNode {
+		return RoleMaster
+	}
+
+	return RoleSlave
+}
@@ -0,0 +1,41 @@
+package db_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestExplainHook(t *testing.T) {
+	var routed []string
+	hook := func(query, role string) {
+		routed = append(routed, role+": "+query)
+	}
+
+	balanced := db.NewDB(&fakeDB{}, []db.Database{&fakeDB{}}, db.WithExplainHook(hook))
+
+	_, _ = balanced.Query("SELECT 1")
+	_, _ = balanced.Exec("DELETE FROM t")
+	_, _ = balanced.Begin()
+
+	want := []string{"slave: SELECT 1", "master: DELETE FROM t", "master: BEGIN"}
+	if !reflect.DeepEqual(routed, want) {
+		t.Errorf("explain hook calls = %v, want %v", routed, want)
+	}
+}
+
+func TestExplainContext(t *testing.T) {
+	balanced := db.NewDB(newSQLiteDB(t), nil)
+
+	plan, err := balanced.ExplainContext(context.Background(), "SELECT * FROM users WHERE name = ?", "alice")
+	if err != nil {
+		t.Fatalf("ExplainContext() error = %v", err)
+	}
+
+	if strings.TrimSpace(plan) == "" {
+		t.Error("ExplainContext() returned an empty plan")
+	}
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// masterFailoverBadConns is the number of consecutive writes failing with a retryable error failing the master over
+const masterFailoverBadConns = 3
+
+// WithMasterFailover replaces the master with the database returned from promote once three consecutive writes,
+// like Exec, Begin, the named execs of GetWriterX, BulkNamedExec and InTxx, failed with a retryable error, see
+// IsRetryableError and WithErrorClassifier.
+// promote gets the failed master and typically promotes a replica, when it returns an error the failed master is
+// kept and the failover is logged.
+// The failed master is not closed. Statements prepared before the failover keep using it.
+func WithMasterFailover(promote func(failed Database) (Database, error)) Option {
+	return func(db *DB) {
+		db.promote = promote
+	}
+}
+
+// observeMaster counts the writes failing with a retryable error and fails the master over after
+// masterFailoverBadConns of them in a row, any other outcome resets the count.
+func (db *DB) observeMaster(err error) {
+	if db.promote == nil {
+		return
+	}
+
+	if !db.retryable(err) {
+		atomic.StoreUint32(&db.badConns, 0)
+		return
+	}
+
+	if atomic.AddUint32(&db.badConns, 1) >= masterFailoverBadConns {
+		db.failover()
+	}
+}
+
+func (db *DB) failover() {
+	// the writes failing while promote runs must not promote another master
+	if !db.failoverMu.TryLock() {
+		return
+	}
+	defer db.failoverMu.Unlock()
+
+	if atomic.LoadUint32(&db.badConns) < masterFailoverBadConns {
+		return
+	}
+
+	failed := db.master()
+	master, err := db.promote(failed)
+	if err == nil && master == nil {
+		err = ErrNilMaster
+	}
+
+	lg := db.logger()
+	if err != nil {
+		if lg != nil {
+			lg.Error("Master failover failed", slog.Any("error", err))
+		}
+
+		return
+	}
+
+	db.mu.Lock()
+	db.pdbs[masterNode] = master
+	db.mu.Unlock()
+
+	db.setNodeError(masterNode, nil)
+	atomic.StoreUint32(&db.badConns, 0)
+	if lg != nil {
+		lg.Warn("Master failed over")
+	}
+}
@@ -0,0 +1,100 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestMasterFailover(t *testing.T) {
+	master, standby := &fakeDB{execErr: driver.ErrBadConn}, &fakeDB{}
+	var failed db.Database
+	balanced := db.NewDB(master, []db.Database{standby}, db.WithMasterFailover(func(f db.Database) (db.Database, error) {
+		failed = f
+		return standby, nil
+	}))
+
+	for i := 0; i < 2; i++ {
+		_, _ = balanced.Exec("DELETE FROM t")
+	}
+	if failed != nil {
+		t.Fatal("failed over before three consecutive bad connections")
+	}
+
+	if _, err := balanced.Exec("DELETE FROM t"); !errors.Is(err, driver.ErrBadConn) {
+		t.Fatalf("Exec() error = %v, want %v", err, driver.ErrBadConn)
+	}
+	if failed != master {
+		t.Fatalf("promote got %v, want the failed master", failed)
+	}
+
+	if _, err := balanced.Exec("DELETE FROM t"); err != nil || standby.writes != 1 {
+		t.Errorf("Exec() after the failover error = %v, standby writes = %d, want 1", err, standby.writes)
+	}
+}
+
+func TestMasterFailoverKeepsMasterWhenPromoteFails(t *testing.T) {
+	master := &fakeDB{execErr: driver.ErrBadConn}
+	promotions := 0
+	balanced := db.NewDB(master, nil, db.WithMasterFailover(func(db.Database) (db.Database, error) {
+		promotions++
+		return nil, errors.New("no standby")
+	}))
+
+	for i := 0; i < 4; i++ {
+		_, _ = balanced.Exec("DELETE FROM t")
+	}
+
+	if promotions != 2 || master.writes != 4 {
+		t.Errorf("promotions = %d, master writes = %d, want 2 and 4", promotions, master.writes)
+	}
+}
+
+// namedFakeDB is a fakeDB which can bind named parameters like sqlx.DB, it records the named queries
+type namedFakeDB struct {
+	fakeDB
+	queries []string
+}
+
+func (f *namedFakeDB) NamedExec(query string, _ interface{}) (sql.Result, error) {
+	return f.NamedExecContext(context.Background(), query, nil)
+}
+
+func (f *namedFakeDB) NamedExecContext(_ context.Context, query string, _ interface{}) (sql.Result, error) {
+	f.queries = append(f.queries, query)
+	return nil, f.execErr
+}
+
+func TestMasterFailoverWriterX(t *testing.T) {
+	master, standby := &namedFakeDB{fakeDB: fakeDB{execErr: driver.ErrBadConn}}, &namedFakeDB{}
+	balanced := db.NewDB(master, nil, db.WithMasterFailover(func(db.Database) (db.Database, error) {
+		return standby, nil
+	}))
+
+	// the handle is taken before the failover and has to follow it
+	w, err := balanced.GetWriterX()
+	if err != nil {
+		t.Fatalf("GetWriterX() error = %v", err)
+	}
+
+	ctx := db.WithQueryTag(context.Background(), map[string]string{"route": "users"})
+	for i := 0; i < 3; i++ {
+		if _, err := w.NamedExecContext(ctx, "DELETE FROM t WHERE id = :id", nil); !errors.Is(err, driver.ErrBadConn) {
+			t.Fatalf("NamedExecContext() error = %v, want %v", err, driver.ErrBadConn)
+		}
+	}
+
+	if _, err := w.NamedExecContext(ctx, "DELETE FROM t WHERE id = :id", nil); err != nil {
+		t.Fatalf("NamedExecContext() after the failover error = %v", err)
+	}
+
+	want := "/*route='users'*/ DELETE FROM t WHERE id = :id"
+	if len(master.queries) != 3 || len(standby.queries) != 1 || standby.queries[0] != want {
+		t.Errorf("master queries = %q, standby queries = %q, want 3 on the master and %q on the standby",
+			master.queries, standby.queries, want)
+	}
+}
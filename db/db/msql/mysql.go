@@ -3,13 +3,21 @@ package msql
 import (
 	//nolint:revive
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/OZahed/db/db"
+
 	//nolint:revive
 	_ "github.com/go-sql-driver/mysql"
 )
 
+const parseTimeParam = "parseTime"
+
 type MySQLConnStringProvider struct {
+	// Params are extra DSN parameters like tls, charset or loc, parseTime=true is added unless it is set here
+	Params       map[string]string
 	Host         string
 	Port         int
 	Proto        string
@@ -27,11 +35,42 @@ func (s *MySQLConnStringProvider) ConnectionString() string {
 		s.Proto = "tcp"
 	}
 
-	return fmt.Sprintf("%s:%s@%s(%s:%d)/%s",
-		s.User, s.Password, s.Proto, s.Host, s.Port, s.DatabaseName,
+	params := url.Values{}
+	params.Set(parseTimeParam, "true")
+	for k, v := range s.Params {
+		params.Set(k, v)
+	}
+
+	return fmt.Sprintf("%s:%s@%s(%s:%d)/%s?%s",
+		s.User, s.Password, s.Proto, s.Host, s.Port, s.DatabaseName, params.Encode(),
 	)
 }
 
+// WithParam sets a DSN parameter like charset or loc.
+func (s *MySQLConnStringProvider) WithParam(key, value string) *MySQLConnStringProvider {
+	if s.Params == nil {
+		s.Params = make(map[string]string)
+	}
+
+	s.Params[key] = value
+	return s
+}
+
+// WithTLS sets the tls DSN parameter.
+func (s *MySQLConnStringProvider) WithTLS(enable bool) *MySQLConnStringProvider {
+	return s.WithParam("tls", strconv.FormatBool(enable))
+}
+
+// WithParseTime sets the parseTime DSN parameter which scans DATE and DATETIME columns into time.Time.
+func (s *MySQLConnStringProvider) WithParseTime(enable bool) *MySQLConnStringProvider {
+	return s.WithParam(parseTimeParam, strconv.FormatBool(enable))
+}
+
 func (s *MySQLConnStringProvider) DBName() string {
 	return s.DatabaseName
 }
+
+// SafeString returns the connection string with the password masked, use it when logging.
+func (s *MySQLConnStringProvider) SafeString() string {
+	return db.MaskDSN(s.ConnectionString())
+}
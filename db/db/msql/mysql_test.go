@@ -0,0 +1,44 @@
+package msql_test
+
+import (
+	"testing"
+
+	"github.com/OZahed/db/db/msql"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestConnectionString(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *msql.MySQLConnStringProvider
+		want     string
+	}{
+		{
+			name: "default params",
+			provider: &msql.MySQLConnStringProvider{
+				Host: "localhost", Port: 3306, User: "user", Password: "pass", DatabaseName: "app",
+			},
+			want: "user:pass@tcp(localhost:3306)/app?parseTime=true",
+		},
+		{
+			name: "overridden params",
+			provider: (&msql.MySQLConnStringProvider{
+				Host: "localhost", Port: 3306, User: "user", Password: "pass", DatabaseName: "app",
+			}).WithTLS(true).WithParseTime(false).WithParam("loc", "Europe/Berlin"),
+			want: "user:pass@tcp(localhost:3306)/app?loc=Europe%2FBerlin&parseTime=false&tls=true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.provider.ConnectionString()
+			if got != tt.want {
+				t.Errorf("ConnectionString() = %v, want %v", got, tt.want)
+			}
+
+			if _, err := mysql.ParseDSN(got); err != nil {
+				t.Errorf("mysql.ParseDSN() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dsnDriver opens the database of driver with another connection string, like the one of a replica
+type dsnDriver struct {
+	SQLDriverInstance
+	dsn string
+}
+
+func (d dsnDriver) ConnectionString() string {
+	return d.dsn
+}
+
+// OpenBalanced opens the master and every replica with NewDatabaseConnectionX and returns them balanced by NewDB
+// configured with opts. The DSNs replace the connection string of driver, its name and database name are kept.
+// Every node gets the pool settings, retries and instrumentation of cfg, the prometheus metrics of each node are
+// labeled with its index, the master is "0" and the replicas follow in order. When a node fails to open the
+// nodes opened before it are closed and the error names the node with its password masked.
+func OpenBalanced(cfg Config, masterDSN string, replicaDSNs []string, driver SQLDriverInstance,
+	opts ...Option,
+) (Database, error) {
+	dsns := append([]string{masterDSN}, replicaDSNs...)
+	nodes := make([]Database, 0, len(dsns))
+	for i, dsn := range dsns {
+		nodeCfg := cfg
+		if cfg.Prometheus {
+			reg := cfg.Registerer
+			if reg == nil {
+				reg = prometheus.DefaultRegisterer
+			}
+			nodeCfg.Registerer = prometheus.WrapRegistererWith(prometheus.Labels{"node": strconv.Itoa(i)}, reg)
+		}
+
+		dbx, err := NewDatabaseConnectionX(nodeCfg, dsnDriver{SQLDriverInstance: driver, dsn: dsn})
+		if err != nil {
+			errs := []error{fmt.Errorf("opening %s %d (%s): %w", nodeRole(i), i, MaskDSN(dsn), err)}
+			for _, node := range nodes {
+				errs = append(errs, node.Close())
+			}
+
+			return nil, errors.Join(errs...)
+		}
+
+		nodes = append(nodes, dbx)
+	}
+
+	return NewDB(nodes[0], nodes[1:], opts...), nil
+}
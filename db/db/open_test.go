@@ -0,0 +1,54 @@
+package db_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/sqlite"
+)
+
+func TestOpenBalanced(t *testing.T) {
+	dir := t.TempDir()
+	reg := prometheus.NewPedanticRegistry()
+	cfg := db.Config{Prometheus: true, Registerer: reg, MaxOpen: 1}
+	replicas := []string{filepath.Join(dir, "replica1.db"), filepath.Join(dir, "replica2.db")}
+
+	balanced, err := db.OpenBalanced(cfg, filepath.Join(dir, "master.db"), replicas,
+		&sqlite.SQLiteConnectionStringProvider{Path: "app.db"})
+	if err != nil {
+		t.Fatalf("OpenBalanced() error = %v", err)
+	}
+	defer balanced.Close()
+
+	if _, err := balanced.Exec("CREATE TABLE users (name TEXT)"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	// the table only exists on the master, so the replicas answer from their own files
+	for i := 0; i < 2; i++ {
+		var tables int
+		if err := balanced.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&tables); err != nil || tables != 0 {
+			t.Fatalf("replica tables = %d, %v, want 0", tables, err)
+		}
+	}
+
+	if got, err := testutil.GatherAndCount(reg, "db_pool_open_connections"); err != nil || got != 3 {
+		t.Errorf("open connections series = %d, %v, want one per node", got, err)
+	}
+}
+
+func TestOpenBalancedFailure(t *testing.T) {
+	cfg := db.Config{}.WithConnectRetry(1, 0)
+	dir := t.TempDir()
+	replicas := []string{filepath.Join(dir, "replica.db"), filepath.Join(dir, "missing", "replica.db")}
+
+	_, err := db.OpenBalanced(cfg, filepath.Join(dir, "master.db"), replicas, &sqlite.SQLiteConnectionStringProvider{})
+	if err == nil || !strings.Contains(err.Error(), "opening slave 2") {
+		t.Fatalf("OpenBalanced() error = %v, want the failing replica", err)
+	}
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+var (
+	ErrNilMaster  = errors.New("balanced db master is nil")
+	ErrNilReplica = errors.New("balanced db replica is nil")
+)
+
+// Option configures a balanced DB created with NewDB
+type Option func(*DB)
+
+// WithSlowQueryThreshold logs the queries taking longer than threshold, see DB.SetLogger.
+func WithSlowQueryThreshold(threshold time.Duration) Option {
+	return func(db *DB) {
+		if threshold > 0 {
+			db.SlowQueryThreshold = threshold
+		}
+	}
+}
+
+// WithLogger sets the logger used for the slow queries.
+func WithLogger(lg *slog.Logger) Option {
+	return func(db *DB) {
+		db.lg = lg
+	}
+}
+
+// WithExplainHook calls hook before every query is dispatched with the role of the node it is routed to,
+// RoleMaster or RoleSlave. Transactions are reported with "BEGIN" as the query.
+func WithExplainHook(hook func(query string, role string)) Option {
+	return func(db *DB) {
+		db.explainHook = hook
+	}
+}
+
+// WithClock replaces the wall clock the slow queries are measured with, tests can use it to make
+// a query take a given time.
+func WithClock(c Clock) Option {
+	return func(db *DB) {
+		db.clock = c
+	}
+}
+
+// WithDefaultReadTimeout bounds Get and Select, the reads without a context which consume their result, to d.
+// Query and QueryRow are not bounded since their rows are read after they return, use QueryContext and
+// QueryRowContext with a deadline instead. The context methods keep the deadline of the caller's context,
+// zero disables the timeout.
+func WithDefaultReadTimeout(d time.Duration) Option {
+	return func(db *DB) {
+		db.readTimeout = d
+	}
+}
+
+// WithDefaultWriteTimeout bounds Exec, the write without a context, to d. Begin is not bounded as the transaction
+// would be rolled back once d elapses. The context methods keep the deadline of the caller's context,
+// zero disables the timeout.
+func WithDefaultWriteTimeout(d time.Duration) Option {
+	return func(db *DB) {
+		db.writeTimeout = d
+	}
+}
+
+// WithRandomStart starts the round-robin of the reads at a random replica, so the first reads of a fleet of
+// processes deployed at once spread over the replicas instead of all hitting the first one. It is off by default
+// to keep the routing deterministic in tests.
+func WithRandomStart(enable bool) Option {
+	return func(db *DB) {
+		if enable {
+			// only spreads the load, it does not need a cryptographic source
+			db.count = rand.Uint64()  //nolint:gosec // G404: not security sensitive
+			db.countX = rand.Uint64() //nolint:gosec // G404: not security sensitive
+		}
+	}
+}
+
+// NewDB creates a balanced DB on top of master and slaves configured by opts,
+// the slaves can be DatabaseX to support the sqlx extensions like Get and Select.
+// A nil master or slave only fails on the first query routed to it, NewValidatedDB reports it instead.
+func NewDB(master Database, slaves []Database, opts ...Option) *DB {
+	db := &DB{clock: realClock{}, closed: make(chan struct{})}
+
+	// check is salves are compatible with DatabaseX interface
+	for i, slave := range slaves {
+		if sx, ok := slave.(DatabaseX); ok {
+			db.xpdbs = append(db.xpdbs, sx)
+			db.xnodes = append(db.xnodes, i+1)
+		}
+	}
+
+	db.pdbs = append([]Database{master}, slaves...)
+	db.reads = make([]uint64, len(db.pdbs))
+	db.inflight = make([]int64, len(db.pdbs))
+	db.down = make([]uint32, len(db.pdbs))
+	db.nodeErrs = make([]error, len(db.pdbs))
+	for i := range slaves {
+		db.replicas = append(db.replicas, i+1)
+	}
+
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	db.localReplicas = db.localNodes(db.replicas)
+	db.localXNodes = db.localNodes(db.xnodes)
+
+	if db.saturationInterval > 0 {
+		go db.monitorPools()
+	}
+
+	return db
+}
+
+// NewValidatedDB works like NewDB but returns ErrNilMaster or ErrNilReplica when master or one of the slaves
+// is nil, including a typed nil like a nil *sql.DB.
+func NewValidatedDB(master Database, slaves []Database, opts ...Option) (*DB, error) {
+	if isNil(master) {
+		return nil, ErrNilMaster
+	}
+
+	for i, slave := range slaves {
+		if isNil(slave) {
+			return nil, fmt.Errorf("%w: slave %d", ErrNilReplica, i)
+		}
+	}
+
+	return NewDB(master, slaves, opts...), nil
+}
+
+func isNil(pdb Database) bool {
+	if pdb == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(pdb)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// statser is implemented by sql.DB, sqlx.DB and the DatabaseX returned from WrapSQLX
+type statser interface {
+	Stats() sql.DBStats
+}
+
+// Stats returns the connection pool statistics of every physical database which exposes them, keyed by its index,
+// the master is 0 and the slaves follow in the order they were given.
+func (db *DB) Stats() map[int]sql.DBStats {
+	stats := make(map[int]sql.DBStats, len(db.pdbs))
+	for i := range db.pdbs {
+		if s, ok := db.node(i).(statser); ok {
+			stats[i] = s.Stats()
+		}
+	}
+
+	return stats
+}
+
+// WithPoolSaturationWarning samples Stats every interval and logs a warning naming the node when the queries
+// waited for a free connection of its pool for more than waitThreshold in total since the previous sample.
+// The sampling stops when the DB is closed.
+func WithPoolSaturationWarning(interval, waitThreshold time.Duration) Option {
+	return func(db *DB) {
+		db.saturationInterval = interval
+		db.saturationThreshold = waitThreshold
+	}
+}
+
+// monitorPools runs until the DB is closed, it is started by NewDB when WithPoolSaturationWarning is set
+func (db *DB) monitorPools() {
+	ticker := time.NewTicker(db.saturationInterval)
+	defer ticker.Stop()
+
+	prev := db.Stats()
+	for {
+		select {
+		case <-db.closed:
+			return
+		case <-ticker.C:
+		}
+
+		stats := db.Stats()
+		for node, s := range stats {
+			db.warnPoolSaturation(node, prev[node], s)
+		}
+
+		prev = stats
+	}
+}
+
+func (db *DB) warnPoolSaturation(node int, prev, cur sql.DBStats) {
+	waited := cur.WaitDuration - prev.WaitDuration
+	if waited <= db.saturationThreshold {
+		return
+	}
+
+	lg := db.logger()
+	if lg == nil {
+		return
+	}
+
+	lg.Warn("Connection pool saturated",
+		slog.Int("node", node),
+		slog.String("role", nodeRole(node)),
+		slog.Duration("waitDuration", waited),
+		slog.Int64("waitCount", cur.WaitCount-prev.WaitCount),
+		slog.Int("inUse", cur.InUse),
+		slog.Int("maxOpenConnections", cur.MaxOpenConnections),
+	)
+}
@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+)
+
+// syncBuffer is a bytes.Buffer which can be written by a background goroutine while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestStats(t *testing.T) {
+	balanced := db.NewDB(newSQLiteDB(t), []db.Database{&fakeDB{}})
+
+	stats := balanced.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("Stats() = %v, want the master only", stats)
+	}
+
+	if got := stats[0].MaxOpenConnections; got != 1 {
+		t.Errorf("master MaxOpenConnections = %d, want 1", got)
+	}
+}
+
+func TestPoolSaturationWarning(t *testing.T) {
+	buf := &syncBuffer{}
+	// the sqlite pool holds a single connection
+	balanced := db.NewDB(newSQLiteDB(t), nil,
+		db.WithLogger(slog.New(slog.NewTextHandler(buf, nil))),
+		db.WithPoolSaturationWarning(5*time.Millisecond, time.Millisecond),
+	)
+	defer balanced.Close()
+
+	tx, err := balanced.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var n int
+		_ = balanced.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	_ = tx.Rollback()
+	<-done
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "Connection pool saturated") {
+		if time.Now().After(deadline) {
+			t.Fatalf("log = %q, want a saturation warning", buf.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if !strings.Contains(buf.String(), "node=0") {
+		t.Errorf("log = %q, want the saturated node", buf.String())
+	}
+}
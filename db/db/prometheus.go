@@ -0,0 +1,84 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsCollector exports the sql.DBStats of a connection pool as prometheus metrics
+type statsCollector struct {
+	mu    sync.RWMutex
+	stats func() sql.DBStats // Stats of the last pool registered for the database, guarded by mu
+
+	open         *prometheus.Desc
+	idle         *prometheus.Desc
+	inUse        *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// newStatsCollector labels the metrics with the driver and the database name, so the pools of several databases
+// can be registered together
+func newStatsCollector(stats func() sql.DBStats, driver SQLDriverInstance) *statsCollector {
+	labels := prometheus.Labels{"driver": driver.Name(), "db_name": driver.DBName()}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("db", "pool", name), help, nil, labels)
+	}
+
+	return &statsCollector{
+		stats:        stats,
+		open:         desc("open_connections", "Number of established connections, both in use and idle."),
+		idle:         desc("idle_connections", "Number of idle connections."),
+		inUse:        desc("in_use_connections", "Number of connections currently in use."),
+		waitCount:    desc("wait_count_total", "Total number of connections waited for."),
+		waitDuration: desc("wait_duration_seconds_total", "Total time blocked waiting for a new connection."),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+	ch <- c.idle
+	ch <- c.inUse
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	stats := c.stats
+	c.mu.RUnlock()
+
+	s := stats()
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+}
+
+// registerPrometheus registers the pool metrics of dbc with reg, prometheus.DefaultRegisterer when it is nil.
+// The *sql.DB can not unregister its collector once it is closed, so when the database is opened again the
+// collector already registered for it is reused and reports the stats of dbc.
+func registerPrometheus(reg prometheus.Registerer, dbc *sql.DB, driver SQLDriverInstance) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	err := reg.Register(newStatsCollector(dbc.Stats, driver))
+
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		if existing, ok := already.ExistingCollector.(*statsCollector); ok {
+			existing.mu.Lock()
+			existing.stats = dbc.Stats
+			existing.mu.Unlock()
+
+			return nil
+		}
+	}
+
+	return err
+}
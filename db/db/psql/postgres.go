@@ -2,19 +2,62 @@ package psql
 
 import (
 	//nolint:revive
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/OZahed/db/db"
 
 	//nolint:revive
 	_ "github.com/lib/pq"
 )
 
+const defaultPort = 5432
+
+var (
+	ErrInvalidSSLMode = errors.New("invalid postgres sslmode")
+	ErrSSLKeyPair     = errors.New("postgres sslcert and sslkey must be set together")
+)
+
+// SSLMode is the libpq sslmode parameter
+type SSLMode string
+
+const (
+	SSLDisable    SSLMode = "disable"
+	SSLAllow      SSLMode = "allow"
+	SSLPrefer     SSLMode = "prefer"
+	SSLRequire    SSLMode = "require"
+	SSLVerifyCA   SSLMode = "verify-ca"
+	SSLVerifyFull SSLMode = "verify-full"
+)
+
+// Valid reports whether the mode is one of the sslmode values accepted by libpq
+func (m SSLMode) Valid() bool {
+	switch m {
+	case SSLDisable, SSLAllow, SSLPrefer, SSLRequire, SSLVerifyCA, SSLVerifyFull:
+		return true
+	default:
+		return false
+	}
+}
+
 type PostgreSQLConnectionStringProvider struct {
+	// Params are extra libpq parameters like connect_timeout or application_name added to the URL
+	Params       map[string]string
 	Host         string
-	Port         int
 	User         string
 	Password     string
 	DatabaseName string
-	SSL          bool
+	// SSLMode defaults to SSLDisable
+	SSLMode SSLMode
+	// SSLRootCert, SSLCert and SSLKey are the paths of the CA certificate and the client certificate and key,
+	// they are only added to the URL with SSLVerifyCA and SSLVerifyFull
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+	// Port defaults to 5432
+	Port int
 }
 
 func (s *PostgreSQLConnectionStringProvider) Name() string {
@@ -26,16 +69,109 @@ func (s *PostgreSQLConnectionStringProvider) DBName() string {
 }
 
 func (s *PostgreSQLConnectionStringProvider) ConnectionString() string {
-	sslMode := "disable"
-	if s.SSL {
-		sslMode = "enable"
+	port := s.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	params := url.Values{}
+	for k, v := range s.Params {
+		params.Set(k, v)
+	}
+	params.Set("sslmode", string(s.sslMode()))
+	for k, v := range s.sslFiles() {
+		params.Set(k, v)
+	}
+
+	// url.URL escapes the credentials, so passwords containing '@', '/' or ':' keep the URL valid
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(s.User, s.Password),
+		Host:     fmt.Sprintf("%s:%d", s.Host, port),
+		Path:     "/" + s.DatabaseName,
+		RawQuery: params.Encode(),
+	}
+
+	return u.String()
+}
+
+// Validate checks the provider settings which would otherwise only fail when connecting
+func (s *PostgreSQLConnectionStringProvider) Validate() error {
+	if !s.sslMode().Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidSSLMode, s.SSLMode)
+	}
+
+	files := s.sslFiles()
+	if (files["sslcert"] == "") != (files["sslkey"] == "") {
+		return ErrSSLKeyPair
+	}
+
+	for k, path := range files {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("postgres %s: %w", k, err)
+		}
 	}
 
-	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=%s",
-		s.User, s.Password, s.Host, s.Port, s.DatabaseName, sslMode,
-	)
+	return nil
+}
+
+// sslFiles returns the non-empty certificate parameters when the sslmode verifies the server
+func (s *PostgreSQLConnectionStringProvider) sslFiles() map[string]string {
+	if mode := s.sslMode(); mode != SSLVerifyCA && mode != SSLVerifyFull {
+		return nil
+	}
+
+	files := make(map[string]string, 3)
+	for k, v := range map[string]string{"sslrootcert": s.SSLRootCert, "sslcert": s.SSLCert, "sslkey": s.SSLKey} {
+		if v != "" {
+			files[k] = v
+		}
+	}
+
+	return files
+}
+
+func (s *PostgreSQLConnectionStringProvider) sslMode() SSLMode {
+	if s.SSLMode == "" {
+		return SSLDisable
+	}
+
+	return s.SSLMode
+}
+
+// WithParam adds a libpq parameter like connect_timeout or application_name to the connection string.
+func (s *PostgreSQLConnectionStringProvider) WithParam(key, value string) *PostgreSQLConnectionStringProvider {
+	if s.Params == nil {
+		s.Params = make(map[string]string)
+	}
+
+	s.Params[key] = value
+	return s
+}
+
+// WithSSLRootCert sets the path of the CA certificate the server certificate is verified with.
+func (s *PostgreSQLConnectionStringProvider) WithSSLRootCert(path string) *PostgreSQLConnectionStringProvider {
+	s.SSLRootCert = path
+	return s
+}
+
+// WithSSLCert sets the path of the client certificate, it needs the key set with WithSSLKey.
+func (s *PostgreSQLConnectionStringProvider) WithSSLCert(path string) *PostgreSQLConnectionStringProvider {
+	s.SSLCert = path
+	return s
+}
+
+// WithSSLKey sets the path of the private key of the client certificate.
+func (s *PostgreSQLConnectionStringProvider) WithSSLKey(path string) *PostgreSQLConnectionStringProvider {
+	s.SSLKey = path
+	return s
 }
 
 func NewPostgreSQLDriverConn() *PostgreSQLConnectionStringProvider {
 	panic("not implemented")
 }
+
+// SafeString returns the connection string with the password masked, use it when logging.
+func (s *PostgreSQLConnectionStringProvider) SafeString() string {
+	return db.MaskDSN(s.ConnectionString())
+}
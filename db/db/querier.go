@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// reader hides every method of the wrapped database except the read methods,
+// wrapping a balanced DB routes every call to the slaves
+type reader struct {
+	q ReadQuerier
+}
+
+// writer routes every call to the master of the balanced DB
+type writer struct {
+	db *DB
+}
+
+// readerX routes every call to the slaves of the balanced DB, Get and Select use the sqlx compatible ones
+type readerX struct {
+	reader
+	db *DB
+}
+
+// writerX routes every call to the sqlx compatible master of the balanced DB, the master is looked up on every call
+// so the handle follows a failover
+type writerX struct {
+	writer
+}
+
+// namedExecer is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type namedExecer interface {
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// GetReader returns a handle which can only read and always uses the slaves,
+// it can be passed to query layers which must never write.
+func (db *DB) GetReader() ReadQuerier {
+	return reader{q: db}
+}
+
+// ReadOnly wraps d in a handle which only exposes the read methods, so writes can not be made through it.
+// When d is a balanced DB the reads are routed to the slaves.
+func ReadOnly(d Database) ReadQuerier {
+	return reader{q: d}
+}
+
+// GetWriter returns a handle pinned to the master.
+func (db *DB) GetWriter() WriteQuerier {
+	return writer{db: db}
+}
+
+// GetReaderX works like GetReader and can scan the results into structs,
+// it fails with ErrNotSQLXCompatible when neither a slave nor the master, which the reads fall back to, is sqlx
+// compatible.
+func (db *DB) GetReaderX() (ReadQuerierX, error) {
+	if !db.sqlxReadable() {
+		return nil, ErrNotSQLXCompatible
+	}
+
+	return readerX{reader: reader{q: db}, db: db}, nil
+}
+
+// GetWriterX works like GetWriter and can bind named parameters,
+// it fails with ErrNotSQLXCompatible when the master is not sqlx compatible.
+func (db *DB) GetWriterX() (WriteQuerierX, error) {
+	if _, ok := db.master().(namedExecer); !ok {
+		return nil, ErrNotSQLXCompatible
+	}
+
+	return writerX{writer: writer{db: db}}, nil
+}
+
+func (r reader) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.q.Query(query, args...)
+}
+
+func (r reader) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.q.QueryContext(ctx, query, args...)
+}
+
+func (r reader) QueryRow(query string, args ...interface{}) *sql.Row {
+	return r.q.QueryRow(query, args...)
+}
+
+func (r reader) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.q.QueryRowContext(ctx, query, args...)
+}
+
+func (w writer) Begin() (*sql.Tx, error) {
+	return w.db.Begin()
+}
+
+func (w writer) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return w.db.BeginTx(ctx, opts)
+}
+
+func (w writer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return w.db.Exec(query, args...)
+}
+
+func (w writer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return w.db.ExecContext(ctx, query, args...)
+}
+
+func (r readerX) Get(dest interface{}, query string, args ...interface{}) error {
+	return r.db.Get(dest, query, args...)
+}
+
+func (r readerX) Select(dest interface{}, query string, args ...interface{}) error {
+	return r.db.Select(dest, query, args...)
+}
+
+func (w writerX) NamedExec(query string, arg interface{}) (sql.Result, error) {
+	return onMaster(context.Background(), w.db, query, func(master Database) (sql.Result, error) {
+		m, ok := master.(namedExecer)
+		if !ok {
+			return nil, ErrNotSQLXCompatible
+		}
+
+		return m.NamedExec(query, arg)
+	})
+}
+
+func (w writerX) NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error) {
+	query = tagQuery(ctx, query)
+	w.db.markWrite(ctx)
+
+	return onMaster(ctx, w.db, query, func(master Database) (sql.Result, error) {
+		m, ok := master.(namedExecer)
+		if !ok {
+			return nil, ErrNotSQLXCompatible
+		}
+
+		return m.NamedExecContext(ctx, query, arg)
+	})
+}
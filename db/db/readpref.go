@@ -0,0 +1,99 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var ErrNoReplicasAvailable = errors.New("no replicas available")
+
+// ReadPreference decides which nodes serve the reads, the writes always go to the master.
+type ReadPreference int
+
+const (
+	// Secondary reads from the slaves, the default. The master only serves reads when there are no slaves,
+	// when none of the slaves is healthy the reads fail with ErrNoReplicasAvailable.
+	Secondary ReadPreference = iota
+	// SecondaryPreferred reads from the slaves and falls back to the master when none of them is healthy.
+	SecondaryPreferred
+	// Primary reads from the master.
+	Primary
+	// PrimaryPreferred reads from the master and falls back to the slaves when it is unhealthy.
+	PrimaryPreferred
+)
+
+func (rp ReadPreference) String() string {
+	switch rp {
+	case Secondary:
+		return "secondary"
+	case SecondaryPreferred:
+		return "secondaryPreferred"
+	case Primary:
+		return "primary"
+	case PrimaryPreferred:
+		return "primaryPreferred"
+	default:
+		return fmt.Sprintf("ReadPreference(%d)", int(rp))
+	}
+}
+
+// WithReadPreference sets which nodes serve the reads of Query, QueryRow, Get, Select and their context variants.
+// Get and Select only fall back to the master when it is sqlx compatible.
+func WithReadPreference(rp ReadPreference) Option {
+	return func(db *DB) {
+		db.readPreference = rp
+	}
+}
+
+// SetHealthy marks the node at index in pdbs, the master is 0 and the slaves follow in the order they were given,
+// as healthy or not. Unhealthy slaves are skipped by the reads as long as a healthy one is left, how the reads
+// fall back when none of them is healthy depends on the ReadPreference. Every node starts as healthy.
+func (db *DB) SetHealthy(node int, healthy bool) {
+	var err error
+	if !healthy {
+		err = errUnhealthy
+	}
+
+	db.setNodeError(node, err)
+}
+
+// errUnhealthy is the error of the nodes marked unhealthy by SetHealthy
+var errUnhealthy = errors.New("marked unhealthy")
+
+// setNodeError marks the node healthy when err is nil and unhealthy otherwise, err is kept for
+// the ErrNoReplicasAvailable errors.
+func (db *DB) setNodeError(node int, err error) {
+	if node < 0 || node >= len(db.down) {
+		return
+	}
+
+	var down uint32
+	if err != nil {
+		down = 1
+	}
+
+	db.mu.Lock()
+	db.nodeErrs[node] = err
+	db.mu.Unlock()
+
+	atomic.StoreUint32(&db.down[node], down)
+}
+
+// noReplicasError wraps ErrNoReplicasAvailable with the error of the last of the replicas which has one
+func (db *DB) noReplicasError(replicas []int) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for i := len(replicas) - 1; i >= 0; i-- {
+		if err := db.nodeErrs[replicas[i]]; err != nil {
+			return fmt.Errorf("%w: node %d: %w", ErrNoReplicasAvailable, replicas[i], err)
+		}
+	}
+
+	return ErrNoReplicasAvailable
+}
+
+func (db *DB) isHealthy(node int) bool {
+	return atomic.LoadUint32(&db.down[node]) == 0
+}
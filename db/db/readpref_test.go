@@ -0,0 +1,73 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestReadPreference(t *testing.T) {
+	tests := []struct {
+		name        string
+		rp          db.ReadPreference
+		unhealthy   []int
+		wantMaster  int
+		wantReplica int
+	}{
+		{name: "secondary", rp: db.Secondary, wantReplica: 4},
+		{name: "secondary skips unhealthy slave", rp: db.Secondary, unhealthy: []int{1}, wantReplica: 4},
+		{name: "secondary without healthy slaves", rp: db.Secondary, unhealthy: []int{1, 2}},
+		{name: "secondary preferred", rp: db.SecondaryPreferred, unhealthy: []int{1}, wantReplica: 4},
+		{name: "secondary preferred falls back", rp: db.SecondaryPreferred, unhealthy: []int{1, 2}, wantMaster: 4},
+		{name: "primary", rp: db.Primary, wantMaster: 4},
+		{name: "primary preferred", rp: db.PrimaryPreferred, wantMaster: 4},
+		{name: "primary preferred falls back", rp: db.PrimaryPreferred, unhealthy: []int{0}, wantReplica: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			master, slaves := &fakeDB{}, []*fakeDB{{}, {}}
+			balanced := db.NewDB(master, []db.Database{slaves[0], slaves[1]}, db.WithReadPreference(tt.rp))
+			for _, node := range tt.unhealthy {
+				balanced.SetHealthy(node, false)
+			}
+
+			for i := 0; i < 4; i++ {
+				_, _ = balanced.Query("SELECT 1")
+			}
+
+			if master.reads != tt.wantMaster {
+				t.Errorf("master reads = %d, want %d", master.reads, tt.wantMaster)
+			}
+
+			if got := slaves[0].reads + slaves[1].reads; got != tt.wantReplica {
+				t.Errorf("slave reads = %d, want %d", got, tt.wantReplica)
+			}
+
+			for _, node := range tt.unhealthy {
+				if node > 0 && len(tt.unhealthy) == 1 && slaves[node-1].reads != 0 {
+					t.Errorf("unhealthy slave %d served %d reads", node, slaves[node-1].reads)
+				}
+			}
+		})
+	}
+}
+
+func TestNoReplicasAvailable(t *testing.T) {
+	errPing := errors.New("connection refused")
+	slaves := []*fakeDB{{pingErr: errPing}, {pingErr: errPing}}
+	balanced := db.NewDB(&fakeDB{}, []db.Database{slaves[0], slaves[1]})
+	balanced.CheckHealth(context.Background())
+
+	_, err := balanced.Query("SELECT 1")
+	if !errors.Is(err, db.ErrNoReplicasAvailable) || !errors.Is(err, errPing) {
+		t.Errorf("err = %v, want ErrNoReplicasAvailable wrapping the ping error", err)
+	}
+
+	balanced.SetHealthy(1, true)
+	if _, err := balanced.Query("SELECT 1"); err != nil {
+		t.Errorf("err = %v after marking a slave healthy, want nil", err)
+	}
+}
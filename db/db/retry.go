@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy is how QueryContextRetry retries a read, Count is the number of retries after the first attempt
+// and Wait the upper bound of the random wait before each retry.
+type RetryPolicy struct {
+	Count int
+	Wait  time.Duration
+}
+
+// QueryContextRetry works like QueryContext and retries the query up to policy.Count times when it fails with
+// a retryable error, see IsRetryableError and WithErrorClassifier. Each retry waits a random duration up to
+// policy.Wait, the full jitter keeps the retries of many callers from hitting the nodes at once, and runs on a
+// healthy replica which was not tried yet as long as there is one. The reads routed to the master, like the ones
+// of the Primary read preference, are retried on the master. It returns the error of ctx as soon as it is done.
+func (db *DB) QueryContextRetry(ctx context.Context, policy RetryPolicy, query string, args ...interface{}) (
+	*sql.Rows, error,
+) {
+	query = tagQuery(ctx, query)
+	node, pdb, err := db.slave(ctx)
+	if err != nil {
+		db.doneReading(node)
+		return nil, err
+	}
+
+	var tried []int
+	for attempt := 0; ; attempt++ {
+		rows, err := db.queryOn(ctx, node, pdb, query, args)
+		db.doneReading(node)
+		if err == nil || attempt >= policy.Count || !db.retryable(err) {
+			return rows, err
+		}
+
+		if err := sleepJitter(ctx, policy.Wait); err != nil {
+			return nil, err
+		}
+
+		tried = append(tried, node)
+		if node, pdb, err = db.retryNode(ctx, node, tried); err != nil {
+			db.doneReading(node)
+			return nil, err
+		}
+	}
+}
+
+// retryNode picks the node retrying a read which failed on node, the next healthy replica not in tried,
+// the routing of slave when all of them were tried and the master when the read was routed to it.
+// Like slave the read is counted as running on the node until doneReading.
+func (db *DB) retryNode(ctx context.Context, node int, tried []int) (int, Database, error) {
+	db.mu.RLock()
+	if node != masterNode && len(db.replicas) > 0 {
+		n := uint64(len(db.replicas))
+		start := atomic.AddUint64(&db.count, 1)
+		for i := uint64(0); i < n; i++ {
+			if idx := db.replicas[(start+i)%n]; db.isHealthy(idx) && !slices.Contains(tried, idx) {
+				atomic.AddInt64(&db.inflight[idx], 1)
+				db.mu.RUnlock()
+
+				atomic.AddUint64(&db.reads[idx], 1)
+				return idx, db.node(idx), nil
+			}
+		}
+	}
+	db.mu.RUnlock()
+
+	return db.slave(ctx)
+}
+
+// sleepJitter waits a random duration up to wait, it returns the error of ctx once it is done
+func sleepJitter(ctx context.Context, wait time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(wait) + 1)))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,88 @@
+package db_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/dbtest"
+)
+
+func TestQueryContextRetry(t *testing.T) {
+	one := func(string, ...any) (*dbtest.Rows, error) { return dbtest.NewRows("1").AddRow(1), nil }
+	errSyntax := errors.New("syntax error")
+	tests := []struct {
+		name      string
+		errs      []error
+		policy    db.RetryPolicy
+		wantErr   error
+		wantCalls []int
+	}{
+		{
+			name:      "retries on other replicas",
+			errs:      []error{nil, driver.ErrBadConn, driver.ErrBadConn},
+			policy:    db.RetryPolicy{Count: 3},
+			wantCalls: []int{1, 1, 1},
+		},
+		{
+			name:      "gives up after count",
+			errs:      []error{driver.ErrBadConn, driver.ErrBadConn, driver.ErrBadConn},
+			policy:    db.RetryPolicy{Count: 1},
+			wantErr:   driver.ErrBadConn,
+			wantCalls: []int{0, 1, 1},
+		},
+		{
+			name:      "does not retry other errors",
+			errs:      []error{nil, errSyntax, nil},
+			policy:    db.RetryPolicy{Count: 3},
+			wantErr:   errSyntax,
+			wantCalls: []int{0, 1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slaves := make([]db.Database, len(tt.errs))
+			fakes := make([]*dbtest.FakeDatabase, len(tt.errs))
+			for i, err := range tt.errs {
+				fakes[i] = &dbtest.FakeDatabase{Err: err, Rows: one}
+				slaves[i] = fakes[i]
+			}
+			balanced := db.NewDB(&dbtest.FakeDatabase{}, slaves)
+
+			rows, err := balanced.QueryContextRetry(context.Background(), tt.policy, "SELECT 1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("QueryContextRetry() error = %v, want %v", err, tt.wantErr)
+			}
+			if rows != nil {
+				_ = rows.Close()
+			}
+
+			for i, fake := range fakes {
+				if got := len(fake.Calls()); got != tt.wantCalls[i] {
+					t.Errorf("slave %d calls = %d, want %d", i+1, got, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryContextRetryCanceled(t *testing.T) {
+	balanced := db.NewDB(&dbtest.FakeDatabase{}, []db.Database{&dbtest.FakeDatabase{Err: driver.ErrBadConn}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := balanced.QueryContextRetry(ctx, db.RetryPolicy{Count: 5, Wait: time.Hour}, "SELECT 1")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("QueryContextRetry() error = %v, want %v", err, context.Canceled)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("QueryContextRetry() returned after %v, want it to stop on cancellation", elapsed)
+	}
+}
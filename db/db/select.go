@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrTooManyRows = errors.New("query returned too many rows")
+
+// scannerType is the type of sql.Scanner, the structs implementing it are scanned as a single column
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// WithMaxSelectRows makes Select fail with ErrTooManyRows once a query returns more than n rows, the rows are
+// scanned one by one so the query is aborted before the whole result is in memory. It needs slaves whose sqlx
+// handle is exposed, like the ones returned from WrapSQLX. Zero, the default, means unlimited.
+func WithMaxSelectRows(n int) Option {
+	return func(db *DB) {
+		db.maxSelectRows = n
+	}
+}
+
+// queryxer is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type queryxer interface {
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+}
+
+// contextQueryerX is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type contextQueryerX interface {
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// getRow runs Get on xdb bounded by ctx when xdb has a context variant
+func getRow(ctx context.Context, xdb DatabaseX, dest interface{}, query string, args []interface{}) error {
+	if cx, ok := xdb.(contextQueryerX); ok {
+		return cx.GetContext(ctx, dest, query, args...)
+	}
+
+	return xdb.Get(dest, query, args...)
+}
+
+// selectRows runs Select on xdb bounded by ctx when xdb has a context variant, when the rows are limited it
+// scans them one by one instead
+func (db *DB) selectRows(ctx context.Context, xdb DatabaseX, dest interface{}, query string,
+	args ...interface{},
+) error {
+	if db.maxSelectRows <= 0 {
+		if cx, ok := xdb.(contextQueryerX); ok {
+			return cx.SelectContext(ctx, dest, query, args...)
+		}
+
+		return xdb.Select(dest, query, args...)
+	}
+
+	q, ok := xdb.(queryxer)
+	if !ok {
+		return fmt.Errorf("limiting the selected rows: %w", ErrNotSQLXCompatible)
+	}
+
+	rows, err := q.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return scanLimited(rows, dest, db.maxSelectRows)
+}
+
+// scanLimited appends the rows to the slice dest points to and fails once there are more than maxRows of them.
+// Like sqlx, the structs with exported fields which do not implement sql.Scanner are scanned by column name.
+func scanLimited(rows *sqlx.Rows, dest interface{}, maxRows int) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("select destination must be a pointer to a slice, got %T", dest)
+	}
+
+	slice := v.Elem()
+	elem := slice.Type().Elem()
+	isPtr := elem.Kind() == reflect.Pointer
+	base := elem
+	if isPtr {
+		base = elem.Elem()
+	}
+
+	structScan := isStructScannable(base)
+	for n := 1; rows.Next(); n++ {
+		if n > maxRows {
+			return fmt.Errorf("%w: more than %d", ErrTooManyRows, maxRows)
+		}
+
+		item := reflect.New(base)
+		var err error
+		if structScan {
+			err = rows.StructScan(item.Interface())
+		} else {
+			err = rows.Scan(item.Interface())
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !isPtr {
+			item = item.Elem()
+		}
+		slice.Set(reflect.Append(slice, item))
+	}
+
+	return rows.Err()
+}
+
+func isStructScannable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || reflect.PointerTo(t).Implements(scannerType) {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+
+	return false
+}
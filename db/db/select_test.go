@@ -0,0 +1,41 @@
+package db_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestMaxSelectRows(t *testing.T) {
+	slave := newSQLiteDB(t)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := slave.Exec("INSERT INTO users (name, age) VALUES (?, 30)", name); err != nil {
+			t.Fatalf("Exec() error = %v", err)
+		}
+	}
+
+	limited := db.NewDB(&fakeDB{}, []db.Database{slave}, db.WithMaxSelectRows(2))
+	var users []user
+	if err := limited.Select(&users, "SELECT name, age FROM users"); !errors.Is(err, db.ErrTooManyRows) {
+		t.Fatalf("Select() error = %v, want %v", err, db.ErrTooManyRows)
+	}
+
+	var names []string
+	if err := limited.Select(&names, "SELECT name FROM users WHERE name <> 'carol' ORDER BY name"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if want := []string{"alice", "bob"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+
+	var ptrs []*user
+	if err := db.NewDB(&fakeDB{}, []db.Database{slave}, db.WithMaxSelectRows(3)).
+		Select(&ptrs, "SELECT name, age FROM users ORDER BY name"); err != nil {
+		t.Fatalf("Select() error = %v", err)
+	}
+	if len(ptrs) != 3 || ptrs[2].Name != "carol" || ptrs[2].Age != 30 {
+		t.Errorf("users = %v, want the 3 users", ptrs)
+	}
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrShardOutOfRange = errors.New("shard index out of range")
+
+// ShardPicker returns the index of the shard a statement should be written to
+type ShardPicker func(query string, args []any) int
+
+// ShardedWriter routes writes to one of several databases, like balanced DBs of each tenant,
+// picking the shard of every statement with a ShardPicker.
+//
+// A transaction can not span shards, start it on a single shard with Shard(i).BeginTx and run all of its
+// statements on the returned transaction.
+type ShardedWriter struct {
+	pick   ShardPicker
+	shards []Database
+}
+
+// NewShardedWriter creates a ShardedWriter over shards, the indexes returned from pick refer to their order.
+func NewShardedWriter(pick ShardPicker, shards ...Database) *ShardedWriter {
+	return &ShardedWriter{pick: pick, shards: shards}
+}
+
+// Shard returns the writer of the shard at index i, use it to start transactions on a single shard.
+func (w *ShardedWriter) Shard(i int) (WriteQuerier, error) {
+	if i < 0 || i >= len(w.shards) {
+		return nil, fmt.Errorf("%w: %d of %d shards", ErrShardOutOfRange, i, len(w.shards))
+	}
+
+	return w.shards[i], nil
+}
+
+// Exec executes the query on the shard picked for it.
+func (w *ShardedWriter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return w.ExecContext(context.Background(), query, args...)
+}
+
+// ExecContext executes the query on the shard picked for it.
+func (w *ShardedWriter) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	shard, err := w.Shard(w.pick(query, args))
+	if err != nil {
+		return nil, err
+	}
+
+	return shard.ExecContext(ctx, query, args...)
+}
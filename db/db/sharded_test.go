@@ -0,0 +1,30 @@
+package db_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestShardedWriter(t *testing.T) {
+	shards := []*fakeDB{{}, {}}
+	byTenant := func(_ string, args []any) int {
+		return args[0].(int) % 2
+	}
+
+	w := db.NewShardedWriter(byTenant, shards[0], shards[1])
+
+	_, _ = w.Exec("UPDATE t SET v = 1 WHERE tenant = ?", 1)
+	_, _ = w.Exec("UPDATE t SET v = 1 WHERE tenant = ?", 3)
+	_, _ = w.Exec("UPDATE t SET v = 1 WHERE tenant = ?", 4)
+
+	if shards[0].writes != 1 || shards[1].writes != 2 {
+		t.Errorf("shard writes = %d/%d, want 1/2", shards[0].writes, shards[1].writes)
+	}
+
+	outOfRange := db.NewShardedWriter(func(string, []any) int { return 2 }, shards[0], shards[1])
+	if _, err := outOfRange.Exec("DELETE FROM t"); !errors.Is(err, db.ErrShardOutOfRange) {
+		t.Errorf("Exec() error = %v, want %v", err, db.ErrShardOutOfRange)
+	}
+}
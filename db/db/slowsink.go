@@ -0,0 +1,59 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WithSlowQuerySink appends every slow query to w as a JSON object per line with the query, a digest of its
+// args, the duration, the node it ran on and the time it started. The records are written even without a
+// logger, concurrent slow queries are serialized and a failing write is dropped without failing the query.
+// The digest is taken after WithArgRedactor, so the args themselves never reach w.
+func WithSlowQuerySink(w io.Writer) Option {
+	return func(db *DB) {
+		db.slowSink = &slowSink{enc: json.NewEncoder(w)}
+	}
+}
+
+// slowRecord is the line written to the sink of WithSlowQuerySink for each slow query
+type slowRecord struct {
+	Time       time.Time `json:"time"`
+	Query      string    `json:"query"`
+	ArgsDigest string    `json:"args_digest,omitempty"`
+	DurationMS float64   `json:"duration_ms"`
+	Role       string    `json:"role"`
+	Node       int       `json:"node"`
+}
+
+// slowSink guards the encoder since the slow queries are reported from concurrent queries
+type slowSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (s *slowSink) write(rec slowRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = s.enc.Encode(rec)
+}
+
+// argsDigest is the sha256 of the args attribute of a slow query, empty when the query has no args
+func argsDigest(attrs []any) string {
+	for _, attr := range attrs {
+		a, ok := attr.(slog.Attr)
+		if !ok || a.Key != argsKey {
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(a.Value.Resolve().String()))
+		return hex.EncodeToString(sum[:])
+	}
+
+	return ""
+}
@@ -0,0 +1,47 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/OZahed/db/db"
+
+	//nolint:revive
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const memory = ":memory:"
+
+// SQLiteConnectionStringProvider opens an embedded SQLite database, either from a file or in memory.
+// It is meant for local development and tests where no database server is available.
+type SQLiteConnectionStringProvider struct {
+	// Path is the database file, it is ignored when Memory is set
+	Path   string
+	Memory bool
+}
+
+func (s *SQLiteConnectionStringProvider) Name() string {
+	return "sqlite3"
+}
+
+func (s *SQLiteConnectionStringProvider) ConnectionString() string {
+	if s.Memory || strings.TrimSpace(s.Path) == "" {
+		return memory
+	}
+
+	return s.Path
+}
+
+// DBName returns the base name of the database file, with its extension, or ":memory:".
+func (s *SQLiteConnectionStringProvider) DBName() string {
+	if s.Memory || strings.TrimSpace(s.Path) == "" {
+		return memory
+	}
+
+	return filepath.Base(s.Path)
+}
+
+// SafeString returns the connection string with the password masked, use it when logging.
+func (s *SQLiteConnectionStringProvider) SafeString() string {
+	return db.MaskDSN(s.ConnectionString())
+}
@@ -0,0 +1,42 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/OZahed/db/db"
+	"github.com/OZahed/db/db/sqlite"
+)
+
+func TestSQLiteConnection(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *sqlite.SQLiteConnectionStringProvider
+		dbName   string
+	}{
+		{name: "memory", provider: &sqlite.SQLiteConnectionStringProvider{Memory: true}, dbName: ":memory:"},
+		{
+			name:     "file",
+			provider: &sqlite.SQLiteConnectionStringProvider{Path: filepath.Join(t.TempDir(), "app.db")},
+			dbName:   "app.db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.provider.DBName(); got != tt.dbName {
+				t.Errorf("DBName() = %q, want %q", got, tt.dbName)
+			}
+
+			dbc, err := db.NewDatabaseConnection(db.Config{MaxOpen: 1}, tt.provider)
+			if err != nil {
+				t.Fatalf("NewDatabaseConnection() error = %v", err)
+			}
+			defer dbc.Close()
+
+			if _, err := dbc.Exec("CREATE TABLE t (id INTEGER)"); err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+		})
+	}
+}
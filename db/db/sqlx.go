@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -13,10 +14,46 @@ type sqlxDB struct {
 	*sqlx.DB
 }
 
+// sqlUnwrapper is implemented by the wrappers around *sql.DB, like instrumented connections,
+// which can give back the underlying connection pool
+type sqlUnwrapper interface {
+	Unwrap() *sql.DB
+}
+
+// WrapSQLX turns db into a DatabaseX. db can be a *sql.DB, a *sqlx.DB, a DatabaseX returned from WrapSQLX
+// or any wrapper with an Unwrap() *sql.DB method. An already sqlx capable db keeps its own driver name.
+// driverName is the name db was opened with, see Config.DriverName for the custom registered ones.
 func WrapSQLX(db Database, driverName string) (DatabaseX, error) {
-	if dbc, ok := db.(*sql.DB); ok {
+	switch dbc := db.(type) {
+	case *sqlxDB:
+		return dbc, nil
+	case *sqlx.DB:
+		return &sqlxDB{dbc}, nil
+	case *sql.DB:
 		return &sqlxDB{sqlx.NewDb(dbc, driverName)}, nil
+	case sqlUnwrapper:
+		if raw := dbc.Unwrap(); raw != nil {
+			return &sqlxDB{sqlx.NewDb(raw, driverName)}, nil
+		}
 	}
 
 	return nil, ErrNotSQLCompatible
 }
+
+// rebinder is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type rebinder interface {
+	Rebind(query string) string
+}
+
+// Rebind transforms a query written with '?' placeholders into the bindvar type of the driver,
+// like $1 for postgres. The first sqlx compatible node, the master first and then the slaves,
+// decides the bindvar type, it fails with ErrNotSQLXCompatible when no node is sqlx compatible.
+func (db *DB) Rebind(query string) (string, error) {
+	for i := range db.pdbs {
+		if r, ok := db.node(i).(rebinder); ok {
+			return r.Rebind(query), nil
+		}
+	}
+
+	return "", fmt.Errorf("rebinding query: %w", ErrNotSQLXCompatible)
+}
@@ -0,0 +1,124 @@
+package db_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/OZahed/db/db"
+	"github.com/jmoiron/sqlx"
+)
+
+// instrumentedDB mimics a connection wrapper which exposes the underlying *sql.DB
+type instrumentedDB struct {
+	*sql.DB
+}
+
+func (i instrumentedDB) Unwrap() *sql.DB {
+	return i.DB
+}
+
+func TestWrapSQLX(t *testing.T) {
+	dbc, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer dbc.Close()
+
+	tests := []struct {
+		name string
+		db   db.Database
+	}{
+		{name: "sql.DB", db: dbc},
+		{name: "sqlx.DB", db: sqlx.NewDb(dbc, "sqlite3")},
+		{name: "unwrapper", db: instrumentedDB{dbc}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbx, err := db.WrapSQLX(tt.db, "sqlite3")
+			if err != nil {
+				t.Fatalf("WrapSQLX() error = %v", err)
+			}
+
+			var one int
+			if err := dbx.Get(&one, "SELECT 1"); err != nil || one != 1 {
+				t.Errorf("Get() = %d, %v, want 1, nil", one, err)
+			}
+		})
+	}
+
+	if _, err := db.WrapSQLX(&fakeDB{}, "sqlite3"); !errors.Is(err, db.ErrNotSQLCompatible) {
+		t.Errorf("WrapSQLX() error = %v, want %v", err, db.ErrNotSQLCompatible)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	dbc, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer dbc.Close()
+
+	balanced := db.NewBalancedDB(0, nil, &fakeDB{}, sqlx.NewDb(dbc, "postgres")).(*db.DB)
+
+	got, err := balanced.Rebind("SELECT * FROM users WHERE name = ? AND age > ?")
+	if err != nil {
+		t.Fatalf("Rebind() error = %v", err)
+	}
+
+	if want := "SELECT * FROM users WHERE name = $1 AND age > $2"; got != want {
+		t.Errorf("Rebind() = %q, want %q", got, want)
+	}
+
+	plain := db.NewBalancedDB(0, nil, &fakeDB{}, &fakeDB{}).(*db.DB)
+	if _, err := plain.Rebind("SELECT ?"); !errors.Is(err, db.ErrNotSQLXCompatible) {
+		t.Errorf("Rebind() error = %v, want %v", err, db.ErrNotSQLXCompatible)
+	}
+}
+
+func TestGetReaderXAndWriterX(t *testing.T) {
+	node := newSQLiteDB(t)
+	balanced := db.NewDB(node, []db.Database{node})
+
+	w, err := balanced.GetWriterX()
+	if err != nil {
+		t.Fatalf("GetWriterX() error = %v", err)
+	}
+
+	alice := user{Name: "alice", Age: 30}
+	if _, err := w.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", alice); err != nil {
+		t.Fatalf("NamedExec() error = %v", err)
+	}
+
+	r, err := balanced.GetReaderX()
+	if err != nil {
+		t.Fatalf("GetReaderX() error = %v", err)
+	}
+
+	var got user
+	if err := r.Get(&got, "SELECT name, age FROM users WHERE name = ?", "alice"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	if got.Age != 30 {
+		t.Errorf("Get() = %+v, want alice aged 30", got)
+	}
+
+	// the reads fall back to a sqlx master when none of the slaves is sqlx compatible
+	masterOnly := db.NewDB(node, []db.Database{&fakeDB{}})
+	if r, err := masterOnly.GetReaderX(); err != nil {
+		t.Errorf("GetReaderX() with a sqlx master error = %v", err)
+	} else if err := r.Get(&got, "SELECT name, age FROM users WHERE name = ?", "alice"); err != nil {
+		t.Errorf("Get() through the sqlx master error = %v", err)
+	}
+
+	plain := db.NewDB(&fakeDB{}, []db.Database{&fakeDB{}})
+	if _, err := plain.GetReaderX(); !errors.Is(err, db.ErrNotSQLXCompatible) {
+		t.Errorf("GetReaderX() error = %v, want %v", err, db.ErrNotSQLXCompatible)
+	}
+
+	if _, err := plain.GetWriterX(); !errors.Is(err, db.ErrNotSQLXCompatible) {
+		t.Errorf("GetWriterX() error = %v, want %v", err, db.ErrNotSQLXCompatible)
+	}
+}
@@ -1,25 +1,121 @@
-// nolint
-// FIXME: add to linter after use
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"sync/atomic"
 
 	"github.com/OZahed/db/internal/helper"
+	"github.com/jmoiron/sqlx"
 )
 
 // Stmt is an aggregate prepared statement.
 // It holds a prepared statement for each underlying physical db.
 type Stmt interface {
 	Close() error
-	Exec(...interface{}) (sql.Result, error)
-	Query(...interface{}) (*sql.Rows, error)
-	QueryRow(...interface{}) *sql.Row
+	Exec(args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error)
+	Query(args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error)
+	QueryRow(args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row
+}
+
+// StmtX is a Stmt prepared on sqlx compatible databases, it can scan the results into structs.
+type StmtX interface {
+	Stmt
+	Get(dest interface{}, args ...interface{}) error
+	GetContext(ctx context.Context, dest interface{}, args ...interface{}) error
+	Select(dest interface{}, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error
+}
+
+// preparer is implemented by sql.DB and sqlx.DB
+type preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// preparerX is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type preparerX interface {
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
 }
 
 type stmt struct {
-	db    *DB
-	stmts []*sql.Stmt
+	db     *DB
+	stmts  []*sql.Stmt
+	xstmts []*sqlx.Stmt // aligned with stmts, only set by PreparexContext
+}
+
+// Prepare creates a prepared statement for later queries or executions
+// on each physical database, concurrently.
+func (db *DB) Prepare(query string) (Stmt, error) {
+	return db.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext creates a prepared statement for later queries or executions
+// on each physical database, concurrently.
+//
+// The provided context is used for the preparation of the statement, not for
+// the execution of the statement. The first failure cancels the preparations
+// still running and closes the statements already prepared.
+func (db *DB) PrepareContext(ctx context.Context, query string) (Stmt, error) {
+	stmts := make([]*sql.Stmt, len(db.pdbs))
+	err := helper.ScatterCtx(ctx, len(db.pdbs), func(ctx context.Context, i int) error {
+		p, ok := db.node(i).(preparer)
+		if !ok {
+			return ErrNotSQLCompatible
+		}
+
+		var err error
+		stmts[i], err = p.PrepareContext(ctx, query)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Join(err, closeStmts(stmts))
+	}
+
+	return &stmt{db: db, stmts: stmts}, nil
+}
+
+// PreparexContext works like PrepareContext but prepares sqlx statements, so the result can be scanned into
+// structs with Get and Select. Every physical database has to be sqlx compatible, see WrapSQLX.
+func (db *DB) PreparexContext(ctx context.Context, query string) (StmtX, error) {
+	xstmts := make([]*sqlx.Stmt, len(db.pdbs))
+	err := helper.ScatterCtx(ctx, len(db.pdbs), func(ctx context.Context, i int) error {
+		p, ok := db.node(i).(preparerX)
+		if !ok {
+			return ErrNotSQLXCompatible
+		}
+
+		var err error
+		xstmts[i], err = p.PreparexContext(ctx, query)
+		return err
+	})
+
+	stmts := make([]*sql.Stmt, len(xstmts))
+	for i, xs := range xstmts {
+		if xs != nil {
+			stmts[i] = xs.Stmt
+		}
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, closeStmts(stmts))
+	}
+
+	return &stmt{db: db, stmts: stmts, xstmts: xstmts}, nil
+}
+
+func closeStmts(stmts []*sql.Stmt) error {
+	var errs []error
+	for _, s := range stmts {
+		if s != nil {
+			errs = append(errs, s.Close())
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // Close closes the statement by concurrently closing all underlying
@@ -37,11 +133,33 @@ func (s *stmt) Exec(args ...interface{}) (sql.Result, error) {
 	return s.stmts[0].Exec(args...)
 }
 
+// ExecContext executes a prepared statement with the given arguments on the master.
+func (s *stmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	return s.stmts[0].ExecContext(ctx, args...)
+}
+
 // Query executes a prepared query statement with the given
 // arguments and returns the query results as a *sql.Rows.
 // Query uses a slave as the underlying physical db.
 func (s *stmt) Query(args ...interface{}) (*sql.Rows, error) {
-	return s.stmts[s.db.acquireSlave(len(s.db.pdbs))].Query(args...)
+	idx, err := s.db.slaveIdx(context.Background())
+	defer s.db.doneReading(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.stmts[idx].Query(args...)
+}
+
+// QueryContext executes a prepared query statement with the given arguments on a slave.
+func (s *stmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	idx, err := s.db.slaveIdx(ctx)
+	defer s.db.doneReading(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.stmts[idx].QueryContext(ctx, args...)
 }
 
 // QueryRow executes a prepared query statement with the given arguments.
@@ -51,5 +169,66 @@ func (s *stmt) Query(args ...interface{}) (*sql.Rows, error) {
 // Otherwise, the *sql.Row's Scan scans the first selected row and discards the rest.
 // QueryRow uses a slave as the underlying physical db.
 func (s *stmt) QueryRow(args ...interface{}) *sql.Row {
-	return s.stmts[s.db.acquireSlave(len(s.db.pdbs))].QueryRow(args...)
+	// a Row can not carry the routing error, without a usable node the query runs on an unhealthy replica
+	idx, _ := s.db.slaveIdx(context.Background())
+	defer s.db.doneReading(idx)
+
+	return s.stmts[idx].QueryRow(args...)
+}
+
+// QueryRowContext executes a prepared query statement with the given arguments on a slave.
+func (s *stmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	// a Row can not carry the routing error, without a usable node the query runs on an unhealthy replica
+	idx, _ := s.db.slaveIdx(ctx)
+	defer s.db.doneReading(idx)
+
+	return s.stmts[idx].QueryRowContext(ctx, args...)
+}
+
+// Get scans the single row returned by the statement into dest, it uses a slave as the underlying physical db.
+func (s *stmt) Get(dest interface{}, args ...interface{}) error {
+	return s.GetContext(context.Background(), dest, args...)
+}
+
+// GetContext scans the single row returned by the statement into dest, it uses a slave as the underlying physical db.
+func (s *stmt) GetContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	idx, xs, err := s.slaveX(ctx)
+	defer s.db.doneReading(idx)
+	if err != nil {
+		return err
+	}
+
+	return xs.GetContext(ctx, dest, args...)
+}
+
+// Select scans the rows returned by the statement into dest, it uses a slave as the underlying physical db.
+func (s *stmt) Select(dest interface{}, args ...interface{}) error {
+	return s.SelectContext(context.Background(), dest, args...)
+}
+
+// SelectContext scans the rows returned by the statement into dest, it uses a slave as the underlying physical db.
+func (s *stmt) SelectContext(ctx context.Context, dest interface{}, args ...interface{}) error {
+	idx, xs, err := s.slaveX(ctx)
+	defer s.db.doneReading(idx)
+	if err != nil {
+		return err
+	}
+
+	return xs.SelectContext(ctx, dest, args...)
+}
+
+// slaveX picks the sqlx statement of the slave serving the next read, the read is counted as running on the node
+// at the returned index until doneReading like slaveIdx.
+func (s *stmt) slaveX(ctx context.Context) (int, *sqlx.Stmt, error) {
+	if s.xstmts == nil {
+		atomic.AddInt64(&s.db.inflight[masterNode], 1)
+		return masterNode, nil, ErrNotSQLXCompatible
+	}
+
+	idx, err := s.db.slaveIdx(ctx)
+	if err != nil {
+		return idx, nil, err
+	}
+
+	return idx, s.xstmts[idx], nil
 }
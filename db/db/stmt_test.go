@@ -0,0 +1,99 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestPreparexRoutesStatements(t *testing.T) {
+	ctx := context.Background()
+	master, slave := newSQLiteDB(t), newSQLiteDB(t)
+	balanced := db.NewBalancedDB(0, nil, master, slave).(*db.DB)
+
+	insert, err := balanced.PreparexContext(ctx, "INSERT INTO users (name, age) VALUES (?, ?)")
+	if err != nil {
+		t.Fatalf("PreparexContext() error = %v", err)
+	}
+	defer insert.Close()
+
+	if _, err := insert.ExecContext(ctx, "master", 1); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+
+	if _, err := slave.Exec("INSERT INTO users (name, age) VALUES ('slave', 2)"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	get, err := balanced.PreparexContext(ctx, "SELECT name, age FROM users WHERE age > ?")
+	if err != nil {
+		t.Fatalf("PreparexContext() error = %v", err)
+	}
+	defer get.Close()
+
+	var u user
+	if err := get.GetContext(ctx, &u, 0); err != nil {
+		t.Fatalf("GetContext() error = %v", err)
+	}
+
+	if u.Name != "slave" {
+		t.Errorf("GetContext() read %q, want the row of the slave", u.Name)
+	}
+
+	var users []user
+	if err := get.Select(&users, 0); err != nil || len(users) != 1 {
+		t.Errorf("Select() = %v, %v, want the single row of the slave", users, err)
+	}
+}
+
+func TestPrepareContext(t *testing.T) {
+	ctx := context.Background()
+	sqliteDB := newSQLiteDB(t)
+
+	s, err := db.NewBalancedDB(0, nil, sqliteDB).(*db.DB).PrepareContext(ctx, "SELECT age FROM users")
+	if err != nil {
+		t.Fatalf("PrepareContext() error = %v", err)
+	}
+	defer s.Close()
+
+	if err := s.QueryRow().Scan(new(int)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("QueryRow().Scan() error = %v, want %v", err, sql.ErrNoRows)
+	}
+
+	if _, err := db.NewBalancedDB(0, nil, &fakeDB{}).(*db.DB).PrepareContext(ctx, "SELECT 1"); err == nil {
+		t.Error("PrepareContext() expected an error for a database which can not prepare statements")
+	}
+}
+
+// blockingPreparer blocks every preparation until its context is canceled
+type blockingPreparer struct {
+	fakeDB
+}
+
+func (b *blockingPreparer) PrepareContext(ctx context.Context, _ string) (*sql.Stmt, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestPrepareContextFailsFast(t *testing.T) {
+	balanced := db.NewBalancedDB(0, nil, &fakeDB{}, &blockingPreparer{}).(*db.DB)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := balanced.PrepareContext(context.Background(), "SELECT 1")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, db.ErrNotSQLCompatible) {
+			t.Errorf("PrepareContext() error = %v, want %v", err, db.ErrNotSQLCompatible)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("PrepareContext() kept waiting for the other nodes after the master failed")
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultTouchTTL is how long the reads of a key touched by TouchWrite go to the master by default
+	defaultTouchTTL = time.Second
+	// minTouchSweep is the number of touched keys kept before the expired ones are swept
+	minTouchSweep = 64
+)
+
+// touchMap holds the keys touched by TouchWrite until they expire
+type touchMap struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	until   map[string]time.Time
+	sweepAt int
+	used    uint32 // Set to 1 by the first TouchWrite, the reads skip the lock until then
+}
+
+// WithTouchTTL sets how long the reads of a key touched by TouchWrite are routed to the master, it should cover
+// the replication lag of the slaves. The default is one second.
+func WithTouchTTL(ttl time.Duration) Option {
+	return func(db *DB) {
+		db.touches.ttl = ttl
+	}
+}
+
+// TouchWrite records that the entity identified by key, like a user id, was just written. Until the TTL set by
+// WithTouchTTL expires, the reads made with the same key as their affinity key, see WithAffinityKey, are routed
+// to the master so they see the write even when the slaves lag behind. An empty key uses the affinity key of ctx.
+// Unlike StickToMasterFor the key is shared by every context, so it also covers the reads of other requests.
+func (db *DB) TouchWrite(ctx context.Context, key string) {
+	if key == "" {
+		var ok bool
+		if key, ok = affinityKey(ctx); !ok {
+			return
+		}
+	}
+
+	t := &db.touches
+	ttl := t.ttl
+	if ttl <= 0 {
+		ttl = defaultTouchTTL
+	}
+
+	now := db.clock.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.until == nil {
+		t.until = make(map[string]time.Time)
+		atomic.StoreUint32(&t.used, 1)
+	}
+
+	t.until[key] = now.Add(ttl)
+	if len(t.until) < max(t.sweepAt, minTouchSweep) {
+		return
+	}
+
+	for k, until := range t.until {
+		if !now.Before(until) {
+			delete(t.until, k)
+		}
+	}
+	t.sweepAt = 2 * len(t.until)
+}
+
+// touched reports whether the affinity key of ctx was touched by TouchWrite within its TTL
+func (db *DB) touched(ctx context.Context) bool {
+	t := &db.touches
+	if atomic.LoadUint32(&t.used) == 0 {
+		return false
+	}
+
+	key, ok := affinityKey(ctx)
+	if !ok {
+		return false
+	}
+
+	t.mu.Lock()
+	until, ok := t.until[key]
+	t.mu.Unlock()
+
+	return ok && db.clock.Now().Before(until)
+}
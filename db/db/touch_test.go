@@ -0,0 +1,39 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestTouchWrite(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	master, slave := &fakeDB{}, &fakeDB{}
+	balanced := db.NewDB(master, []db.Database{slave}, db.WithClock(clock), db.WithTouchTTL(time.Second))
+
+	alice := db.WithAffinityKey(context.Background(), "user:alice")
+	bob := db.WithAffinityKey(context.Background(), "user:bob")
+
+	balanced.TouchWrite(context.Background(), "user:alice")
+	_, _ = balanced.QueryContext(alice, "SELECT 1")
+	_, _ = balanced.QueryContext(bob, "SELECT 1")
+	_, _ = balanced.QueryContext(context.Background(), "SELECT 1")
+	if master.reads != 1 || slave.reads != 2 {
+		t.Errorf("master/slave reads = %d/%d, want only the touched key on the master", master.reads, slave.reads)
+	}
+
+	// the affinity key of ctx is touched when key is empty
+	balanced.TouchWrite(bob, "")
+	_, _ = balanced.QueryContext(bob, "SELECT 1")
+	if master.reads != 2 {
+		t.Errorf("master reads = %d, want the read of the key of ctx on the master", master.reads)
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	_, _ = balanced.QueryContext(alice, "SELECT 1")
+	if slave.reads != 3 {
+		t.Errorf("slave reads = %d, want the read after the TTL on the slave", slave.reads)
+	}
+}
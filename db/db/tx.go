@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// txxBeginner is implemented by sqlx.DB and the DatabaseX returned from WrapSQLX
+type txxBeginner interface {
+	BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+}
+
+// txCloser is implemented by sql.Tx and sqlx.Tx
+type txCloser interface {
+	Commit() error
+	Rollback() error
+}
+
+// InTx runs fn in a transaction on the master. The transaction is committed when fn returns nil and rolled back
+// when it returns an error, the rollback error is joined to it. When fn panics the transaction is rolled back
+// and the panic is propagated.
+func (db *DB) InTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	return runTx(tx, func() error { return fn(tx) })
+}
+
+// InTxx works like InTx with a sqlx transaction, the master has to be sqlx compatible, see WrapSQLX.
+func (db *DB) InTxx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sqlx.Tx) error) error {
+	db.markWrite(ctx)
+
+	tx, err := onMaster(ctx, db, "BEGIN", func(master Database) (*sqlx.Tx, error) {
+		m, ok := master.(txxBeginner)
+		if !ok {
+			return nil, ErrNotSQLXCompatible
+		}
+
+		return m.BeginTxx(ctx, opts)
+	})
+	if err != nil {
+		return err
+	}
+
+	return runTx(tx, func() error { return fn(tx) })
+}
+
+func runTx(tx txCloser, fn func() error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,83 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/OZahed/db/db"
+	"github.com/jmoiron/sqlx"
+)
+
+func countUsers(t *testing.T, q db.ReadQuerier) int {
+	t.Helper()
+
+	var n int
+	if err := q.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("counting users: %v", err)
+	}
+
+	return n
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	balanced := db.NewDB(newSQLiteDB(t), nil)
+	errAbort := errors.New("abort")
+
+	err := balanced.InTx(ctx, nil, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO users (name, age) VALUES ('alice', 30)")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTx() error = %v", err)
+	}
+
+	err = balanced.InTx(ctx, nil, func(tx *sql.Tx) error {
+		_, _ = tx.Exec("INSERT INTO users (name, age) VALUES ('bob', 40)")
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("InTx() error = %v, want %v", err, errAbort)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Error("InTx() did not propagate the panic")
+			}
+		}()
+
+		_ = balanced.InTx(ctx, nil, func(tx *sql.Tx) error {
+			_, _ = tx.Exec("INSERT INTO users (name, age) VALUES ('carol', 50)")
+			panic("boom")
+		})
+	}()
+
+	if got := countUsers(t, balanced); got != 1 {
+		t.Errorf("users = %d, want only the committed one", got)
+	}
+}
+
+func TestInTxx(t *testing.T) {
+	ctx := context.Background()
+	balanced := db.NewDB(newSQLiteDB(t), nil)
+
+	err := balanced.InTxx(ctx, nil, func(tx *sqlx.Tx) error {
+		_, err := tx.NamedExec("INSERT INTO users (name, age) VALUES (:name, :age)", user{Name: "alice", Age: 30})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("InTxx() error = %v", err)
+	}
+
+	if got := countUsers(t, balanced); got != 1 {
+		t.Errorf("users = %d, want 1", got)
+	}
+
+	plain := db.NewDB(&fakeDB{}, nil)
+	if err := plain.InTxx(ctx, nil, func(*sqlx.Tx) error { return nil }); !errors.Is(err, db.ErrNotSQLXCompatible) {
+		t.Errorf("InTxx() error = %v, want %v", err, db.ErrNotSQLXCompatible)
+	}
+}
@@ -0,0 +1,35 @@
+package db
+
+// WithReplicaZones tags the slaves with the zone, like the availability zone, they run in. The keys are the
+// indexes in pdbs like SetHealthy, the slaves start at 1. See WithLocalZone.
+func WithReplicaZones(zones map[int]string) Option {
+	return func(db *DB) {
+		db.replicaZones = zones
+	}
+}
+
+// WithLocalZone sets the zone of the process, the reads prefer the healthy slaves tagged with the same zone by
+// WithReplicaZones and only cross zones when none of them is healthy. A slave without a zone counts as remote.
+// Without a local zone, or without a slave in it, the reads spread over all the slaves.
+// An affinity key, see WithAffinityKey, still routes to its replica whatever its zone is.
+func WithLocalZone(zone string) Option {
+	return func(db *DB) {
+		db.localZone = zone
+	}
+}
+
+// localNodes returns the nodes tagged with the local zone
+func (db *DB) localNodes(nodes []int) []int {
+	if db.localZone == "" {
+		return nil
+	}
+
+	var local []int
+	for _, idx := range nodes {
+		if db.replicaZones[idx] == db.localZone {
+			local = append(local, idx)
+		}
+	}
+
+	return local
+}
@@ -0,0 +1,45 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/OZahed/db/db"
+)
+
+func TestLocalZone(t *testing.T) {
+	zones := map[int]string{1: "eu-west-1a", 2: "eu-west-1b", 3: "eu-west-1a"}
+	tests := []struct {
+		name      string
+		localZone string
+		unhealthy []int
+		want      []int
+	}{
+		{name: "local replicas", localZone: "eu-west-1a", want: []int{2, 0, 2}},
+		{name: "crosses zones", localZone: "eu-west-1a", unhealthy: []int{1, 3}, want: []int{0, 4, 0}},
+		{name: "no local replica", localZone: "eu-west-1c", want: []int{1, 2, 1}},
+		{name: "zones not configured", want: []int{1, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slaves := []*fakeDB{{}, {}, {}}
+			balanced := db.NewDB(&fakeDB{}, []db.Database{slaves[0], slaves[1], slaves[2]},
+				db.WithReplicaZones(zones),
+				db.WithLocalZone(tt.localZone),
+			)
+			for _, node := range tt.unhealthy {
+				balanced.SetHealthy(node, false)
+			}
+
+			for i := 0; i < 4; i++ {
+				_, _ = balanced.Query("SELECT 1")
+			}
+
+			for i, slave := range slaves {
+				if slave.reads != tt.want[i] {
+					t.Errorf("slave %d reads = %d, want %d", i+1, slave.reads, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,12 @@
 package helper
 
-import "golang.org/x/sync/errgroup"
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
 
 func Scatter(n int, fn func(i int) error) error {
 	g := errgroup.Group{}
@@ -12,3 +18,37 @@ func Scatter(n int, fn func(i int) error) error {
 
 	return g.Wait()
 }
+
+// ScatterCtx runs fn for every index concurrently and returns the first error. The context passed to fn is
+// canceled as soon as a call fails or ctx is done, so the calls checking it can give up early.
+// Like Scatter it waits for every call to return.
+func ScatterCtx(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	g, gctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < n; i++ {
+		i := i
+		g.Go(func() error { return fn(gctx, i) })
+	}
+
+	return g.Wait()
+}
+
+// ScatterCollect runs fn for every index concurrently and returns the results aligned by index.
+// Unlike Scatter it waits for every call and joins all the errors, so one failure does not hide another.
+func ScatterCollect[T any](n int, fn func(i int) (T, error)) ([]T, error) {
+	results := make([]T, n)
+	errs := make([]error, n)
+
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = fn(i)
+		}()
+	}
+
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
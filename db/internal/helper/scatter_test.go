@@ -0,0 +1,63 @@
+package helper_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/OZahed/db/internal/helper"
+)
+
+func TestScatterCollect(t *testing.T) {
+	errOdd := errors.New("odd index")
+
+	results, err := helper.ScatterCollect(4, func(i int) (int, error) {
+		if i%2 == 1 {
+			return 0, errOdd
+		}
+
+		return i * 10, nil
+	})
+
+	if want := []int{0, 0, 20, 0}; !reflect.DeepEqual(results, want) {
+		t.Errorf("ScatterCollect() results = %v, want %v", results, want)
+	}
+
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("ScatterCollect() error = %v, want %v", err, errOdd)
+	}
+
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Errorf("ScatterCollect() joined %d errors, want 2", n)
+	}
+}
+
+func TestScatterCtxCancelsOnFirstError(t *testing.T) {
+	errFirst := errors.New("first node failed")
+	var canceled int32
+
+	err := helper.ScatterCtx(context.Background(), 4, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return errFirst
+		}
+
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&canceled, 1)
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("ScatterCtx() error = %v, want %v", err, errFirst)
+	}
+
+	if got := atomic.LoadInt32(&canceled); got != 3 {
+		t.Errorf("%d calls saw the cancellation, want 3", got)
+	}
+}
@@ -0,0 +1,103 @@
+package log
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithBufferedOutput buffers up to size bytes of records in memory and writes them to the output at once,
+// trading the latency of the records for fewer write syscalls. The buffer is flushed when it is full, every
+// flushInterval when it is positive and by the closer of NewSlogCloser or Logger.Close, the records still
+// buffered when the process exits without closing the logger are lost. It has no effect with WithSyslog,
+// which sends a message per record, and the text handler is not colorized when the output is buffered.
+func WithBufferedOutput(size int, flushInterval time.Duration) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.BufferSize = size
+		cfg.FlushInterval = flushInterval
+	}
+}
+
+// bufferedWriter is a bufio.Writer safe for concurrent use which is flushed in the background
+type bufferedWriter struct {
+	mu     sync.Mutex
+	bw     *bufio.Writer
+	out    io.Writer
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newBufferedWriter(out io.Writer, size int, flushInterval time.Duration) *bufferedWriter {
+	b := &bufferedWriter{bw: bufio.NewWriterSize(out, size), out: out}
+	if flushInterval > 0 {
+		b.stop = make(chan struct{})
+		b.done = make(chan struct{})
+		go b.flushEvery(flushInterval)
+	}
+
+	return b
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+
+	return b.bw.Write(p)
+}
+
+// Flush writes the buffered records to the output
+func (b *bufferedWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.bw.Flush()
+}
+
+func (b *bufferedWriter) flushEvery(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Flush()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background flush, flushes the buffered records and closes the output, see closeOutput
+func (b *bufferedWriter) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	if b.stop != nil {
+		close(b.stop)
+		<-b.done
+	}
+
+	b.mu.Lock()
+	err := b.bw.Flush()
+	b.mu.Unlock()
+
+	if cerr := closeOutput(b.out); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncRecorder is a closeRecorder which can be read while the background flush writes to it
+type syncRecorder struct {
+	mu sync.Mutex
+	closeRecorder
+}
+
+func (s *syncRecorder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeRecorder.Write(p)
+}
+
+func (s *syncRecorder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.closeRecorder.String()
+}
+
+func TestBufferedOutput(t *testing.T) {
+	out := &closeRecorder{}
+	lg := NewLogger(WithOutput(out), WithBufferedOutput(4096, 0))
+
+	lg.Info("first")
+	lg.Info("second")
+	if out.Len() != 0 {
+		t.Fatalf("output = %q, want the records buffered", out.String())
+	}
+
+	if err := lg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := strings.Count(out.String(), "\n"); got != 2 || out.closed != 1 {
+		t.Errorf("output = %q closed %d times, want both records flushed and the output closed", out.String(), out.closed)
+	}
+}
+
+func TestBufferedOutputFlushInterval(t *testing.T) {
+	out := &syncRecorder{}
+	lg := NewLogger(WithOutput(out), WithBufferedOutput(4096, time.Millisecond))
+	defer lg.Close()
+
+	lg.Info("flushed in the background")
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(out.String(), "flushed in the background") {
+		if time.Now().After(deadline) {
+			t.Fatal("the record was not flushed by the background flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
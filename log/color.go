@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+const colorReset = "\x1b[0m"
+
+// levelColors are the ANSI colors of the levels, the levels between them, like WARN+2, use the color of their prefix
+var levelColors = []struct {
+	level string
+	color string
+}{
+	{level: "ERROR", color: "\x1b[31m"},
+	{level: "WARN", color: "\x1b[33m"},
+	{level: "INFO", color: "\x1b[32m"},
+	{level: "DEBUG", color: "\x1b[34m"},
+}
+
+var levelPrefix = []byte("level=")
+
+// colorWriter colorizes the level of the records written by the text handler, which writes a record per Write.
+// The level can not be colorized in ReplaceAttr, the text handler would quote the escape sequences.
+type colorWriter struct {
+	w io.Writer
+}
+
+func (c *colorWriter) Write(p []byte) (int, error) {
+	start := bytes.Index(p, levelPrefix)
+	if start < 0 {
+		return c.w.Write(p)
+	}
+
+	start += len(levelPrefix)
+	end := bytes.IndexByte(p[start:], ' ')
+	if end < 0 {
+		return c.w.Write(p)
+	}
+	end += start
+
+	color := levelColor(p[start:end])
+	if color == "" {
+		return c.w.Write(p)
+	}
+
+	buf := make([]byte, 0, len(p)+len(color)+len(colorReset))
+	buf = append(buf, p[:start]...)
+	buf = append(buf, color...)
+	buf = append(buf, p[start:end]...)
+	buf = append(buf, colorReset...)
+	buf = append(buf, p[end:]...)
+
+	if _, err := c.w.Write(buf); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func levelColor(level []byte) string {
+	for _, lc := range levelColors {
+		if bytes.HasPrefix(level, []byte(lc.level)) {
+			return lc.color
+		}
+	}
+
+	return ""
+}
+
+// isTerminal reports whether w is a character device like a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
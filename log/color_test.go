@@ -0,0 +1,42 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestColorWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := slog.New(slog.NewTextHandler(&colorWriter{w: buf}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	lg.Error("boom")
+	lg.Log(context.Background(), slog.LevelWarn+2, "almost an error")
+	lg.Debug("level=INFO in the message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"level=\x1b[31mERROR\x1b[0m msg=boom",
+		"level=\x1b[33mWARN+2\x1b[0m msg=",
+		"level=\x1b[34mDEBUG\x1b[0m msg=",
+	}
+
+	for i, line := range lines {
+		if !strings.Contains(line, want[i]) {
+			t.Errorf("line %d = %q, want %q", i, line, want[i])
+		}
+	}
+}
+
+func TestColorOnlyOnTerminal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := slog.New(newHandler(buf, slogOptions{HandlerType: TextHandler, Color: true}))
+
+	lg.Error("boom")
+
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("record = %q, want no colors when the output is not a terminal", buf.String())
+	}
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+const (
+	stackKey      = "stack"
+	errorKey      = "error"
+	errorStackKey = "errorStack"
+	// logWithStackDepth skips runtime.Callers, logWithStack and the exported *WithStack method
+	logWithStackDepth = 3
+)
+
+// Logger wraps slog.Logger and adds helpers which attach the call-site stack to the record.
+// With and WithGroup return a *Logger, so the stack attribute is nested under the current groups.
+type Logger struct {
+	*slog.Logger
+	maxStackFrames int
+	closer         io.Closer
+}
+
+// NewLogger creates a Logger on top of NewSlog with the provided options.
+func NewLogger(opts ...slogOptionFunc) *Logger {
+	var opt slogOptions
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	lg, closer := NewSlogCloser(opts...)
+	return &Logger{Logger: lg, maxStackFrames: opt.MaxStackFrames, closer: closer}
+}
+
+// Close flushes and closes the output of the logger, see WithOutput, it is a no-op for os.Stdout.
+// The loggers derived with With and WithGroup share the output, closing any of them closes it for all.
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+
+	return l.closer.Close()
+}
+
+// With returns a Logger that includes the given attributes in each output operation.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{Logger: l.Logger.With(args...), maxStackFrames: l.maxStackFrames, closer: l.closer}
+}
+
+// WithGroup returns a Logger that starts a group, all attributes added to the logger, including the stack,
+// will be qualified by the given name.
+func (l *Logger) WithGroup(name string) *Logger {
+	return &Logger{Logger: l.Logger.WithGroup(name), maxStackFrames: l.maxStackFrames, closer: l.closer}
+}
+
+// DebugWithStack logs at LevelDebug with the caller stack attached as a "stack" attribute.
+func (l *Logger) DebugWithStack(msg string, args ...any) {
+	l.logWithStack(context.Background(), slog.LevelDebug, msg, args...)
+}
+
+// InfoWithStack logs at LevelInfo with the caller stack attached as a "stack" attribute.
+func (l *Logger) InfoWithStack(msg string, args ...any) {
+	l.logWithStack(context.Background(), slog.LevelInfo, msg, args...)
+}
+
+// WarnWithStack logs at LevelWarn with the caller stack attached as a "stack" attribute.
+func (l *Logger) WarnWithStack(msg string, args ...any) {
+	l.logWithStack(context.Background(), slog.LevelWarn, msg, args...)
+}
+
+// ErrorWithStack logs at LevelError with the caller stack attached as a "stack" attribute.
+func (l *Logger) ErrorWithStack(msg string, args ...any) {
+	l.logWithStack(context.Background(), slog.LevelError, msg, args...)
+}
+
+// logWithStack builds the record by hand so the source points to the caller of the *WithStack method
+// and not to this file.
+func (l *Logger) logWithStack(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(logWithStackDepth, pcs[:])
+
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	r.AddAttrs(slog.String(stackKey, getStackFrame(logWithStackDepth, l.maxStackFrames)))
+
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// ErrorErr logs at LevelError with every layer of the err chain, walked with errors.Unwrap, attached under
+// the "error" group as "error.0" for err itself, "error.1" for the error it wraps and so on.
+// If a layer has a StackTrace method, like the errors of github.com/pkg/errors, the trace of the innermost one
+// is attached as "errorStack".
+func (l *Logger) ErrorErr(msg string, err error, args ...any) {
+	l.logErr(context.Background(), msg, err, args...)
+}
+
+// logErr works like logWithStack, the depth of runtime.Callers is the same as it is called from an exported method
+func (l *Logger) logErr(ctx context.Context, msg string, err error, args ...any) {
+	if !l.Enabled(ctx, slog.LevelError) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(logWithStackDepth, pcs[:])
+
+	r := slog.NewRecord(time.Now(), slog.LevelError, msg, pcs[0])
+	r.Add(args...)
+
+	var (
+		chain []any
+		stack string
+	)
+	for i, e := 0, err; e != nil; i, e = i+1, errors.Unwrap(e) {
+		chain = append(chain, slog.String(strconv.Itoa(i), e.Error()))
+		if st, ok := errorStackTrace(e); ok {
+			stack = st
+		}
+	}
+
+	if len(chain) > 0 {
+		r.AddAttrs(slog.Group(errorKey, chain...))
+	}
+
+	if stack != "" {
+		r.AddAttrs(slog.String(errorStackKey, stack))
+	}
+
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// errorStackTrace calls the StackTrace method of err if it has one. The return type differs between libraries,
+// so the method is looked up by name and its result is formatted with %+v which prints the frames with file:line.
+func errorStackTrace(err error) (string, bool) {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return "", false
+	}
+
+	return fmt.Sprintf("%+v", m.Call(nil)[0].Interface()), true
+}
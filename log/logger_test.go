@@ -0,0 +1,127 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestErrorWithStackInGroup(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := &Logger{Logger: slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true}))}
+
+	lg.WithGroup("req").ErrorWithStack("failed", slog.String("id", "42"))
+
+	var record struct {
+		Source struct {
+			Function string `json:"function"`
+		} `json:"source"`
+		Stack string `json:"stack"`
+		Req   struct {
+			ID    string `json:"id"`
+			Stack string `json:"stack"`
+		} `json:"req"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if record.Stack != "" {
+		t.Errorf("stack escaped the group: %q", record.Stack)
+	}
+
+	if record.Req.ID != "42" {
+		t.Errorf("req.id = %q, want %q", record.Req.ID, "42")
+	}
+
+	if !strings.Contains(record.Req.Stack, "TestErrorWithStackInGroup") {
+		t.Errorf("req.stack = %q, want the caller stack", record.Req.Stack)
+	}
+
+	if !strings.HasSuffix(record.Source.Function, "TestErrorWithStackInGroup") {
+		t.Errorf("source function = %q, want the caller", record.Source.Function)
+	}
+}
+
+type tracedError struct{ error }
+
+func (tracedError) StackTrace() []string { return []string{"main.go:42"} }
+
+func TestErrorErr(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := &Logger{Logger: slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{AddSource: true}))}
+
+	root := tracedError{errors.New("connection refused")}
+	lg.ErrorErr("query failed", fmt.Errorf("loading user: %w", fmt.Errorf("querying: %w", root)))
+
+	var record struct {
+		Source struct {
+			Function string `json:"function"`
+		} `json:"source"`
+		Error      map[string]string `json:"error"`
+		ErrorStack string            `json:"errorStack"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	want := map[string]string{
+		"0": "loading user: querying: connection refused",
+		"1": "querying: connection refused",
+		"2": "connection refused",
+	}
+	for k, v := range want {
+		if record.Error[k] != v {
+			t.Errorf("error.%s = %q, want %q", k, record.Error[k], v)
+		}
+	}
+
+	if record.ErrorStack != "[main.go:42]" {
+		t.Errorf("errorStack = %q, want the wrapped error stack trace", record.ErrorStack)
+	}
+
+	if !strings.HasSuffix(record.Source.Function, "TestErrorErr") {
+		t.Errorf("source function = %q, want the caller", record.Source.Function)
+	}
+}
+
+// closeRecorder is an output counting how many times it was closed
+type closeRecorder struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestLoggerClose(t *testing.T) {
+	out := &closeRecorder{}
+	lg := NewLogger(WithOutput(out), WithHandlerType(JsonHandler))
+
+	lg.With("id", 42).Info("shutting down")
+	if !strings.Contains(out.String(), `"msg":"shutting down"`) {
+		t.Errorf("output = %q, want the record", out.String())
+	}
+
+	if err := lg.WithGroup("req").Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if err := lg.Close(); err != nil {
+		t.Fatalf("second Close() error = %v", err)
+	}
+
+	if out.closed != 1 {
+		t.Errorf("output closed %d times, want once", out.closed)
+	}
+
+	if err := NewLogger().Close(); err != nil {
+		t.Errorf("Close() of the stdout logger error = %v", err)
+	}
+}
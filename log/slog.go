@@ -2,10 +2,14 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 )
 
 // HandlerType determines which type of Handler should be used for the logger
@@ -20,6 +24,10 @@ const (
 	maxDepthOfLogger         = 25
 	runtimeMain              = "runtime.main"
 	replaceAttrFunctionStack = 7
+
+	otlpSeverityKey   = "severity"
+	otlpBodyKey       = "body"
+	otlpAttributesKey = "attributes"
 )
 
 // slogOptions is a configuration struct for the ReplaceAttr function
@@ -35,11 +43,33 @@ type slogOptions struct {
 	SkipStack int
 	// AddStack is a flag to determine if the stack should be added to the log
 	AddStack bool
+	// MaxStackFrames caps the number of frames in the stack, maxDepthOfLogger is used when it is not positive
+	MaxStackFrames int
 
 	// ReplaceAttrEnable is a flag to determine if the ReplaceAttr function should be enabled
 	ReplaceAttrEnable bool
 	// AlwaysUTC is a flag to determine if the time should always be in UTC regardless of your system timezone
 	AlwaysUTC bool
+	// OTLPFormat is a flag to determine if the records should use the OTLP log data model keys
+	OTLPFormat bool
+	// DefaultAttrs are added to every record of the logger
+	DefaultAttrs []slog.Attr
+	// HumanDurations is a flag to determine if the durations should be written like "1.2s" by every handler type
+	HumanDurations bool
+	// AddSource is a flag to determine if the source of the record should be added to the log
+	AddSource bool
+	// Color is a flag to determine if the text handler should colorize the level when writing to a terminal
+	Color bool
+	// Output is where the records are written, os.Stdout is used when it is nil
+	Output io.Writer
+	// Syslog is a flag to determine if the records should be written as RFC 5424 syslog messages
+	Syslog bool
+	// SyslogTag is the app name of the syslog messages
+	SyslogTag string
+	// BufferSize is the size of the buffer holding the records before they are written, zero disables it
+	BufferSize int
+	// FlushInterval is the period the buffered records are flushed at, zero only flushes a full buffer
+	FlushInterval time.Duration
 }
 
 type slogOptionFunc func(*slogOptions)
@@ -71,42 +101,176 @@ func WithReplceAttrFunc(replaceAttr func(groups []string, a slog.Attr) slog.Attr
 	}
 }
 
+// WithStackFrame adds the caller stack to the source of every record, it is WithStackFrameSkip with the default depth.
+// The stack is captured while the handler writes the record, the records below the level cost no capture.
 func WithStackFrame() slogOptionFunc {
+	return WithStackFrameSkip(replaceAttrFunctionStack)
+}
+
+// WithStackFrameSkip adds the caller stack to the source of every record starting n frames above the function
+// building the stack. The default is 7, the caller of the slog.Logger method, add one for every wrapper
+// function the logger is called through so the stack starts at the caller of the wrapper.
+func WithStackFrameSkip(n int) slogOptionFunc {
 	return func(cfg *slogOptions) {
 		cfg.ReplaceAttrEnable = true
-		cfg.SkipStack = replaceAttrFunctionStack
+		cfg.SkipStack = n
 		cfg.AddStack = true
 	}
 }
 
+// WithMaxStackFrames caps the number of frames captured in the stacks added to the records.
+func WithMaxStackFrames(n int) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.MaxStackFrames = n
+	}
+}
+
+// WithHumanDurations decides if the durations are written like "1.2s", the way the text handler writes them,
+// or as nanoseconds by the JSON handler. It is enabled by default so both handler types write the same values.
+func WithHumanDurations(enable bool) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.HumanDurations = enable
+	}
+}
+
+// WithDefaultAttrs adds attrs, like the service name, version or environment, to every record of the logger.
+// They go through the ReplaceAttr function like any other attribute.
+func WithDefaultAttrs(attrs ...slog.Attr) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.DefaultAttrs = append(cfg.DefaultAttrs, attrs...)
+	}
+}
+
+// WithSource decides if the file, line and function of the caller are added to every record. Finding them costs
+// a runtime.Callers call per record, the stack of WithStackFrame is part of the source and goes away with it.
+// It is enabled by default.
+func WithSource(enable bool) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.AddSource = enable
+	}
+}
+
+// WithColor colorizes the level of the text handler records, red for errors, yellow for warnings, green for info
+// and blue for debug. It is meant for local development, the records are left alone when the output is not a
+// terminal or the handler is the JSON one.
+func WithColor(enable bool) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.Color = enable
+	}
+}
+
+// WithOutput writes the records to w instead of os.Stdout. When w is an io.Closer, like a file or a buffered
+// writer, it is closed by the closer of NewSlogCloser and by Logger.Close so the last records are not lost.
+func WithOutput(w io.Writer) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.Output = w
+	}
+}
+
+// WithOTLPFormat emits JSON records using the OTLP log data model keys, "severity" and "body" instead of
+// "level" and "msg", with every attribute of the record nested under "attributes".
+func WithOTLPFormat() slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.HandlerType = JsonHandler
+		cfg.ReplaceAttrEnable = true
+		cfg.OTLPFormat = true
+	}
+}
+
 // NewSlog function provides a new logger instance from the slog package
 // with the provided options.
 func NewSlog(opts ...slogOptionFunc) *slog.Logger {
+	lg, _ := NewSlogCloser(opts...)
+	return lg
+}
+
+// NewSlogCloser works like NewSlog and also returns the closer flushing and closing the output of the logger,
+// call it on shutdown. It flushes the buffer of WithBufferedOutput, closing os.Stdout, os.Stderr or an output
+// which is not an io.Closer is a no-op.
+func NewSlogCloser(opts ...slogOptionFunc) (*slog.Logger, io.Closer) {
 	// Default Options
 	opt := slogOptions{
 		HandlerType:       TextHandler,
 		Level:             "debug",
 		ReplaceAttrEnable: false,
+		HumanDurations:    true,
+		AddSource:         true,
 	}
 
 	for _, o := range opts {
 		o(&opt)
 	}
 
+	w := opt.Output
+	if w == nil {
+		w = os.Stdout
+	}
+
+	if opt.BufferSize > 0 && !opt.Syslog {
+		w = newBufferedWriter(w, opt.BufferSize, opt.FlushInterval)
+	}
+
+	return slog.New(newHandler(w, opt)), &outputCloser{w: w}
+}
+
+// outputCloser closes the output of a logger once, the loggers derived with With and WithGroup share it
+type outputCloser struct {
+	once sync.Once
+	w    io.Writer
+	err  error
+}
+
+func (c *outputCloser) Close() error {
+	c.once.Do(func() {
+		c.err = closeOutput(c.w)
+	})
+
+	return c.err
+}
+
+// closeOutput closes w when it is an io.Closer, os.Stdout and os.Stderr are left open for the rest of the process
+func closeOutput(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+
+	if cl, ok := w.(io.Closer); ok {
+		return cl.Close()
+	}
+
+	return nil
+}
+
+func newHandler(w io.Writer, opt slogOptions) slog.Handler {
 	var handlerFunc slog.Handler
 	handlerOptions := &slog.HandlerOptions{
-		AddSource:   true,
+		AddSource:   opt.AddSource,
 		Level:       getLoggerLevel(opt.Level),
 		ReplaceAttr: makeReplaceAttr(opt),
 	}
-	switch opt.HandlerType {
-	case JsonHandler:
-		handlerFunc = slog.NewJSONHandler(os.Stdout, handlerOptions)
+	switch {
+	case opt.Syslog:
+		handlerFunc = newSyslogHandler(w, opt, handlerOptions)
+	case opt.HandlerType == JsonHandler:
+		handlerFunc = slog.NewJSONHandler(w, handlerOptions)
 	default:
-		handlerFunc = slog.NewTextHandler(os.Stdout, handlerOptions)
+		if opt.Color && isTerminal(w) {
+			w = &colorWriter{w: w}
+		}
+
+		handlerFunc = slog.NewTextHandler(w, handlerOptions)
+	}
+
+	if opt.OTLPFormat {
+		// the built-in keys are not affected by groups, so only the attributes are nested
+		handlerFunc = handlerFunc.WithGroup(otlpAttributesKey)
 	}
 
-	return slog.New(handlerFunc)
+	if len(opt.DefaultAttrs) > 0 {
+		handlerFunc = handlerFunc.WithAttrs(opt.DefaultAttrs)
+	}
+
+	return handlerFunc
 }
 
 func getLoggerLevel(level string) slog.Level {
@@ -123,7 +287,9 @@ func getLoggerLevel(level string) slog.Level {
 }
 
 func makeReplaceAttr(cfg slogOptions) func(groups []string, a slog.Attr) slog.Attr {
-	if !cfg.ReplaceAttrEnable {
+	// the text handler already writes the durations like "1.2s"
+	humanJSONDurations := cfg.HumanDurations && cfg.HandlerType == JsonHandler
+	if !cfg.ReplaceAttrEnable && !humanJSONDurations {
 		return nil
 	}
 
@@ -133,15 +299,20 @@ func makeReplaceAttr(cfg slogOptions) func(groups []string, a slog.Attr) slog.At
 
 	return func(groups []string, a slog.Attr) slog.Attr {
 		switch {
+		case cfg.OTLPFormat && len(groups) == 0 && a.Key == slog.LevelKey:
+			a.Key = otlpSeverityKey
+		case cfg.OTLPFormat && len(groups) == 0 && a.Key == slog.MessageKey:
+			a.Key = otlpBodyKey
 		case a.Key == slog.TimeKey && cfg.AlwaysUTC:
 			a.Value = slog.TimeValue(a.Value.Time().UTC())
-		case cfg.HandlerType == JsonHandler && a.Value.Kind() == slog.KindDuration:
+		case humanJSONDurations && a.Value.Kind() == slog.KindDuration:
 			a.Value = slog.StringValue(a.Value.Duration().String())
 		case cfg.AddStack && a.Key == slog.SourceKey:
 			src := a.Value.Any().(*slog.Source)
-			stack := getStackFrame(cfg.SkipStack)
+			stack := getStackFrame(cfg.SkipStack, cfg.MaxStackFrames)
 			return slog.Group(slog.SourceKey,
-				"caller", fmt.Sprintf("%s:%d\n\t%s:%d", src.File, src.Line, src.Function, src.Line),
+				"caller", fmt.Sprintf("%s:%d", src.File, src.Line),
+				"function", src.Function,
 				"callerStack", stack)
 		}
 
@@ -149,22 +320,49 @@ func makeReplaceAttr(cfg slogOptions) func(groups []string, a slog.Attr) slog.At
 	}
 }
 
-func getStackFrame(depth int) (stackFrameInfo string) {
-	for i := depth; i < maxDepthOfLogger; i++ {
-		pc, file, line, ok := runtime.Caller(i)
-		if !ok {
+// packageDir is the directory of this package, frames of its non-test files are left out of the stacks
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// getStackFrame returns at most maxFrames frames of the stack starting at depth,
+// frames of the runtime, log/slog and this package are skipped.
+func getStackFrame(depth, maxFrames int) (stackFrameInfo string) {
+	if maxFrames <= 0 || maxFrames > maxDepthOfLogger {
+		maxFrames = maxDepthOfLogger
+	}
+
+	// runtime.Callers counts itself as the frame 0 unlike runtime.Caller
+	pcs := make([]uintptr, maxDepthOfLogger+depth)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(depth+1, pcs)])
+
+	for n := 0; n < maxFrames; {
+		frame, more := frames.Next()
+		if frame.Function == runtimeMain {
 			break
 		}
 
-		funcInfo := runtime.FuncForPC(pc)
-		funcName := funcInfo.Name()
+		if !skipFrame(frame) {
+			stackFrameInfo = fmt.Sprintf("%s%s:%d\n\t%s\n", stackFrameInfo, frame.File, frame.Line, frame.Function)
+			n++
+		}
 
-		if funcName == runtimeMain {
+		if !more {
 			break
 		}
-
-		stackFrameInfo = fmt.Sprintf("%s%s:%d\n\t%s:%d\n", stackFrameInfo, file, line, funcName, line)
 	}
 
 	return stackFrameInfo
 }
+
+func skipFrame(frame runtime.Frame) bool {
+	switch {
+	case strings.HasPrefix(frame.Function, "runtime."), strings.HasPrefix(frame.Function, "log/slog."):
+		return true
+	case filepath.Dir(frame.File) == packageDir:
+		return !strings.HasSuffix(frame.File, "_test.go")
+	default:
+		return false
+	}
+}
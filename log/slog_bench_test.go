@@ -0,0 +1,66 @@
+package log
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The stack is captured in ReplaceAttr, which only runs for the records passing Enabled, so the suppressed
+// records do not pay for it:
+//
+//	BenchmarkStackFrame/error_at_error              12333 ns/op    1824 B/op    27 allocs/op
+//	BenchmarkStackFrame/info_suppressed_at_error    11.80 ns/op       0 B/op     0 allocs/op
+func BenchmarkStackFrame(b *testing.B) {
+	opt := slogOptions{HandlerType: JsonHandler, Level: "error", AddSource: true}
+	WithStackFrame()(&opt)
+	lg := slog.New(newHandler(io.Discard, opt))
+
+	b.Run("error at error", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			lg.Error("hello")
+		}
+	})
+
+	b.Run("info suppressed at error", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			lg.Info("hello")
+		}
+	})
+}
+
+// Buffering the output writes many records per syscall:
+//
+//	BenchmarkOutput/unbuffered    2485 ns/op    24 B/op    2 allocs/op
+//	BenchmarkOutput/buffered      1599 ns/op    24 B/op    2 allocs/op
+func BenchmarkOutput(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []slogOptionFunc
+	}{
+		{name: "unbuffered"},
+		{name: "buffered", opts: []slogOptionFunc{WithBufferedOutput(64<<10, 100*time.Millisecond)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			f, err := os.Create(filepath.Join(b.TempDir(), "bench.log"))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			opts := append([]slogOptionFunc{WithOutput(f), WithHandlerType(JsonHandler), WithSource(false)}, bc.opts...)
+			lg, closer := NewSlogCloser(opts...)
+			defer closer.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lg.Info("hello", "i", i)
+			}
+		})
+	}
+}
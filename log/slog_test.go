@@ -0,0 +1,214 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(buf *bytes.Buffer, opts ...slogOptionFunc) *slog.Logger {
+	opt := slogOptions{HandlerType: JsonHandler, Level: "debug", HumanDurations: true, AddSource: true}
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	return slog.New(newHandler(buf, opt))
+}
+
+func TestStackFrameCallerFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithStackFrame())
+
+	lg.Info("hello")
+
+	var record struct {
+		Source struct {
+			Caller      string `json:"caller"`
+			Function    string `json:"function"`
+			CallerStack string `json:"callerStack"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if !regexp.MustCompile(`.*\.go:\d+$`).MatchString(record.Source.Caller) {
+		t.Errorf("caller = %q, want file:line", record.Source.Caller)
+	}
+
+	if !strings.HasSuffix(record.Source.Function, "TestStackFrameCallerFormat") {
+		t.Errorf("function = %q, want test function name", record.Source.Function)
+	}
+
+	if !strings.Contains(record.Source.CallerStack, "log.TestStackFrameCallerFormat") {
+		t.Errorf("callerStack = %q, want real function names", record.Source.CallerStack)
+	}
+}
+
+func TestOTLPFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithOTLPFormat())
+
+	lg.Warn("hello \"world\"\n", slog.String("user", "ünïcode"), slog.Group("req", slog.Int("id", 1)))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if record["severity"] != "WARN" || record["body"] != "hello \"world\"\n" {
+		t.Errorf("severity/body = %v/%v, want WARN and the message", record["severity"], record["body"])
+	}
+
+	if _, ok := record["level"]; ok {
+		t.Error("record still has the level key")
+	}
+
+	attributes, _ := record["attributes"].(map[string]any)
+	if attributes["user"] != "ünïcode" {
+		t.Errorf("attributes = %v, want the user attribute", attributes)
+	}
+
+	if req, _ := attributes["req"].(map[string]any); req["id"] != float64(1) {
+		t.Errorf("attributes.req = %v, want the nested group", attributes["req"])
+	}
+}
+
+func TestStackFrameStartsAtCaller(t *testing.T) {
+	stack := getStackFrame(1, 0)
+
+	lines := strings.Split(stack, "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[1], "log.TestStackFrameStartsAtCaller") {
+		t.Fatalf("first frame = %q, want the caller", lines)
+	}
+
+	if strings.Contains(stack, "runtime.") {
+		t.Errorf("stack = %q, want no runtime frames", stack)
+	}
+}
+
+func TestWithMaxStackFrames(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithStackFrame(), WithMaxStackFrames(1))
+
+	lg.Info("hello")
+
+	var record struct {
+		Source struct {
+			CallerStack string `json:"callerStack"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if got := strings.Count(record.Source.CallerStack, "\n\t"); got != 1 {
+		t.Errorf("callerStack has %d frames, want 1: %q", got, record.Source.CallerStack)
+	}
+
+	if !strings.HasSuffix(strings.TrimSpace(record.Source.CallerStack), "log.TestWithMaxStackFrames") {
+		t.Errorf("callerStack = %q, want the caller", record.Source.CallerStack)
+	}
+}
+
+func TestStackFrameSkipWrapper(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithStackFrameSkip(replaceAttrFunctionStack+1), WithMaxStackFrames(1))
+
+	// logInfo is the wrapper layer the extra skipped frame accounts for
+	logInfo := func(msg string) {
+		lg.Info(msg)
+	}
+	logInfo("hello")
+
+	var record struct {
+		Source struct {
+			CallerStack string `json:"callerStack"`
+		} `json:"source"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal log record: %v", err)
+	}
+
+	if !strings.HasSuffix(strings.TrimSpace(record.Source.CallerStack), "log.TestStackFrameSkipWrapper") {
+		t.Errorf("callerStack = %q, want the caller of the wrapper", record.Source.CallerStack)
+	}
+}
+
+func TestWithDefaultAttrs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []slogOptionFunc
+		path []string
+	}{
+		{name: "json", path: []string{}},
+		{name: "otlp", opts: []slogOptionFunc{WithOTLPFormat()}, path: []string{"attributes"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			opts := append([]slogOptionFunc{WithDefaultAttrs(slog.String("service", "api"), slog.String("env", "prod"))},
+				tt.opts...)
+			lg := newTestLogger(buf, opts...)
+
+			lg.Info("hello", slog.Int("id", 1))
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("unmarshal log record: %v", err)
+			}
+
+			for _, key := range tt.path {
+				record, _ = record[key].(map[string]any)
+			}
+
+			if record["service"] != "api" || record["env"] != "prod" || record["id"] != float64(1) {
+				t.Errorf("record = %v, want the default attrs next to the record attrs", record)
+			}
+		})
+	}
+}
+
+func TestHumanDurations(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []slogOptionFunc
+		want string
+	}{
+		{name: "json", want: `"d":"1.2s"`},
+		{name: "json without replace attr", opts: []slogOptionFunc{WithLevel("info")}, want: `"d":"1.2s"`},
+		{name: "json nanoseconds", opts: []slogOptionFunc{WithHumanDurations(false)}, want: `"d":1200000000`},
+		{name: "text", opts: []slogOptionFunc{WithHandlerType(TextHandler)}, want: `d=1.2s`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			lg := newTestLogger(buf, tt.opts...)
+
+			lg.Info("hello", slog.Duration("d", 1200*time.Millisecond))
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("record = %q, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWithSource(t *testing.T) {
+	for _, enable := range []bool{true, false} {
+		buf := &bytes.Buffer{}
+		lg := newTestLogger(buf, WithSource(enable))
+
+		lg.Info("hello")
+
+		if got := strings.Contains(buf.String(), `"source"`); got != enable {
+			t.Errorf("WithSource(%t): record = %q", enable, buf.String())
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// syslogFacilityUser is the facility of the records, user-level messages
+	syslogFacilityUser = 1
+	// syslogSDID is the id of the structured data element holding the attributes, 32473 is the private enterprise
+	// number reserved for documentation by RFC 5612
+	syslogSDID         = "slog@32473"
+	syslogTimeFormat   = "2006-01-02T15:04:05.000000Z07:00"
+	syslogMaxParamName = 32
+	syslogMaxAppName   = 48
+)
+
+// syslogLocalPaths are the sockets the local syslog daemon or journald listen on
+var syslogLocalPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// WithSyslog writes the records to syslog as RFC 5424 messages tagged with tag as the app name. The level is
+// mapped to the severity, errors to err, warnings to warning, info to info and debug to debug, and the attributes
+// are written as the structured data of the message. An empty network and addr write to the local syslog daemon
+// or journald through /dev/log, otherwise the connection is dialed like net.Dial and redialed after a failed write.
+// The connection is closed by Logger.Close. The handler type, the color and the stack frames have no effect.
+func WithSyslog(network, addr, tag string) slogOptionFunc {
+	return func(cfg *slogOptions) {
+		cfg.Syslog = true
+		cfg.SyslogTag = tag
+		cfg.Output = &syslogWriter{network: network, addr: addr}
+	}
+}
+
+// syslogSeverity maps the slog levels to the syslog severities, the levels between them use the lower one
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogHandler is a slog.Handler writing every record as an RFC 5424 message in a single Write
+type syslogHandler struct {
+	mu       *sync.Mutex
+	w        io.Writer
+	opts     slog.HandlerOptions
+	utc      bool
+	hostname string
+	appName  string
+	procID   string
+
+	groups []string
+	prefix string // The groups joined with dots, prefixing the names of the attributes
+	params []byte // The attributes added by WithAttrs already formatted
+}
+
+func newSyslogHandler(w io.Writer, opt slogOptions, opts *slog.HandlerOptions) *syslogHandler {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = ""
+	}
+
+	return &syslogHandler{
+		mu:       &sync.Mutex{},
+		w:        w,
+		opts:     *opts,
+		utc:      opt.AlwaysUTC,
+		hostname: syslogName(hostname, 255),
+		appName:  syslogName(opt.SyslogTag, syslogMaxAppName),
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+}
+
+func (h *syslogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+
+	return level >= minLevel
+}
+
+func (h *syslogHandler) Handle(_ context.Context, r slog.Record) error {
+	buf := make([]byte, 0, 256)
+	buf = fmt.Appendf(buf, "<%d>1 ", syslogFacilityUser*8+syslogSeverity(r.Level))
+
+	if r.Time.IsZero() {
+		buf = append(buf, '-')
+	} else {
+		t := r.Time
+		if h.utc {
+			t = t.UTC()
+		}
+		buf = t.AppendFormat(buf, syslogTimeFormat)
+	}
+
+	buf = fmt.Appendf(buf, " %s %s %s - ", h.hostname, h.appName, h.procID)
+
+	params := append([]byte(nil), h.params...)
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		params = appendSyslogParam(params, slog.SourceKey, fmt.Sprintf("%s:%d", frame.File, frame.Line))
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		params = h.appendAttr(params, h.prefix, h.groups, a)
+		return true
+	})
+
+	if len(params) == 0 {
+		buf = append(buf, '-')
+	} else {
+		buf = append(buf, "["+syslogSDID...)
+		buf = append(buf, params...)
+		buf = append(buf, ']')
+	}
+
+	buf = append(buf, ' ')
+	buf = append(buf, r.Message...)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.params = append([]byte(nil), h.params...)
+	for _, a := range attrs {
+		h2.params = h.appendAttr(h2.params, h.prefix, h.groups, a)
+	}
+
+	return &h2
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.groups = append(append([]string(nil), h.groups...), name)
+	h2.prefix = h.prefix + name + "."
+
+	return &h2
+}
+
+// appendAttr appends a as a structured data parameter, the attributes of a group are flattened with dotted names
+func (h *syslogHandler) appendAttr(params []byte, prefix string, groups []string, a slog.Attr) []byte {
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+	}
+
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return params
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		return appendSyslogParam(params, prefix+a.Key, a.Value.String())
+	}
+
+	// a group with an empty key is inlined
+	if a.Key != "" {
+		prefix += a.Key + "."
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+	}
+
+	for _, ga := range a.Value.Group() {
+		params = h.appendAttr(params, prefix, groups, ga)
+	}
+
+	return params
+}
+
+// appendSyslogParam appends a PARAM-NAME="PARAM-VALUE" pair, the characters not allowed in the name are replaced
+// and the ones which must be escaped in the value are escaped
+func appendSyslogParam(params []byte, name, value string) []byte {
+	params = append(params, ' ')
+	params = append(params, syslogName(name, syslogMaxParamName)...)
+	params = append(params, '=', '"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\', ']':
+			params = append(params, '\\', c)
+		default:
+			params = append(params, c)
+		}
+	}
+
+	return append(params, '"')
+}
+
+// syslogName makes s a valid header field or PARAM-NAME of at most n printable ASCII characters,
+// "-" stands for an empty one
+func syslogName(s string, n int) string {
+	if s == "" {
+		return "-"
+	}
+
+	if len(s) > n {
+		s = s[:n]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+
+		return r
+	}, s)
+}
+
+// syslogWriter sends every Write as a message to the syslog server, the stream connections frame the messages
+// with their length as RFC 6587 describes, the local stream sockets with a newline like the local daemons expect
+type syslogWriter struct {
+	network string
+	addr    string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	// a failed write is retried once on a new connection, the server may have restarted
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return 0, err
+			}
+		}
+
+		if _, err = s.conn.Write(s.frame(p)); err == nil {
+			return len(p), nil
+		}
+
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+
+	return 0, err
+}
+
+func (s *syslogWriter) frame(p []byte) []byte {
+	switch s.conn.LocalAddr().Network() {
+	case "tcp", "tcp4", "tcp6":
+		return append([]byte(strconv.Itoa(len(p))+" "), p...)
+	case "unix":
+		// a newline in the message would end it early, it is escaped like in a Go string
+		return append(bytes.ReplaceAll(p, []byte("\n"), []byte(`\n`)), '\n')
+	default:
+		return p
+	}
+}
+
+func (s *syslogWriter) dial() (net.Conn, error) {
+	if s.network != "" || s.addr != "" {
+		return net.DialTimeout(s.network, s.addr, 5*time.Second)
+	}
+
+	for _, path := range syslogLocalPaths {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no local syslog socket found in %v", syslogLocalPaths)
+}
+
+func (s *syslogWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
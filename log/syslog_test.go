@@ -0,0 +1,119 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithSyslog("", "", "my app"), WithSource(false), WithAlwaysUTC(true))
+
+	lg.WithGroup("req").With("id", "4]2").Error("failed", "quote", `say "hi"`)
+
+	msg := buf.String()
+	if !strings.HasPrefix(msg, "<11>1 ") {
+		t.Errorf("message = %q, want the user facility and err severity", msg)
+	}
+
+	want := fmt.Sprintf(` my_app %d - [slog@32473 req.id="4\]2" req.quote="say \"hi\""] failed`, os.Getpid())
+	if !strings.HasSuffix(msg, want) {
+		t.Errorf("message = %q, want the suffix %q", msg, want)
+	}
+
+	ts := strings.Fields(msg)[1]
+	if _, err := time.Parse(syslogTimeFormat, ts); err != nil || !strings.HasSuffix(ts, "Z") {
+		t.Errorf("timestamp = %q, want an RFC 5424 UTC timestamp: %v", ts, err)
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	buf := &bytes.Buffer{}
+	lg := newTestLogger(buf, WithSyslog("", "", "app"))
+
+	for _, tt := range []struct {
+		log  func(msg string, args ...any)
+		want string
+	}{
+		{log: lg.Debug, want: "<15>1 "},
+		{log: lg.Info, want: "<14>1 "},
+		{log: lg.Warn, want: "<12>1 "},
+		{log: lg.Error, want: "<11>1 "},
+	} {
+		buf.Reset()
+		tt.log("hello")
+		if !strings.HasPrefix(buf.String(), tt.want) {
+			t.Errorf("message = %q, want the prefix %q", buf.String(), tt.want)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listening on udp: %v", err)
+	}
+	defer server.Close()
+
+	lg := NewLogger(WithSyslog("udp", server.LocalAddr().String(), "app"), WithLevel("info"))
+	lg.Info("started", "port", 8080)
+
+	if err := lg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+	packet := make([]byte, 1024)
+	n, _, err := server.ReadFrom(packet)
+	if err != nil {
+		t.Fatalf("reading the syslog message: %v", err)
+	}
+
+	if msg := string(packet[:n]); !strings.HasPrefix(msg, "<14>1 ") || !strings.HasSuffix(msg, `port="8080"] started`) {
+		t.Errorf("message = %q, want the info record", msg)
+	}
+}
+
+func TestSyslogUnixStreamNewline(t *testing.T) {
+	// not t.TempDir, its path can be longer than a unix socket path allows
+	dir, err := os.MkdirTemp("", "syslog")
+	if err != nil {
+		t.Fatalf("creating the socket directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, err := net.Listen("unix", dir+"/log")
+	if err != nil {
+		t.Skipf("listening on unix: %v", err)
+	}
+	defer server.Close()
+
+	lg := NewLogger(WithSyslog("unix", server.Addr().String(), "app"), WithLevel("info"))
+	lg.Info("first line\nsecond line")
+
+	if err := lg.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatalf("accepting the syslog connection: %v", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	var stream bytes.Buffer
+	if _, err := stream.ReadFrom(conn); err != nil {
+		t.Fatalf("reading the syslog messages: %v", err)
+	}
+
+	msg := stream.String()
+	if strings.Count(msg, "\n") != 1 || !strings.HasSuffix(msg, ` first line\nsecond line`+"\n") {
+		t.Errorf("stream = %q, want a single message with the newline escaped", msg)
+	}
+}